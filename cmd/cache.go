@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/spf13/cobra"
+)
+
+func createCacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the on disk cache of cloned service repos",
+	}
+
+	cacheCmd.AddCommand(createCachePruneCmd())
+	cacheCmd.AddCommand(createCacheClearCmd())
+
+	return cacheCmd
+}
+
+func createCachePruneCmd() *cobra.Command {
+	var olderThan = new(time.Duration)
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Removes cached repos that haven't been used recently",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := openCache(cmd)
+			if err != nil {
+				return err
+			}
+
+			removed, err := cache.Prune(*olderThan)
+			for _, repo := range removed {
+				fmt.Printf("removed %s\n", repo)
+			}
+
+			return err
+		},
+	}
+
+	pruneCmd.Flags().DurationVar(olderThan, "older-than", 30*24*time.Hour, "remove repos not used for this long")
+
+	return pruneCmd
+}
+
+func createCacheClearCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Removes every cached repo",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := openCache(cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := cache.Clear(); err != nil {
+				return err
+			}
+
+			fmt.Printf("cleared %s\n", cache.Dir)
+			return nil
+		},
+	}
+}
+
+func openCache(cmd *cobra.Command) (*git.Cache, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return git.CacheFromConfig(cfg)
+}
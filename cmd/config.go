@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// loadConfig loads the config file and applies any flag overrides on top.
+// Flags take precedence over environment variables, which take precedence over the file.
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	path, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]*string{
+		"github-org": &cfg.GitHub.Org,
+		"jira-host":  &cfg.Jira.Host,
+	}
+
+	for name, field := range overrides {
+		if !cmd.Flags().Changed(name) {
+			continue
+		}
+
+		if *field, err = cmd.Flags().GetString(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// --template is either a notes template or a directory of templates
+	if flag := cmd.Flags().Lookup("template"); flag != nil && flag.Changed {
+		info, err := os.Stat(flag.Value.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to open template: %w", err)
+		}
+
+		if info.IsDir() {
+			cfg.Templates.Dir = flag.Value.String()
+		} else {
+			cfg.Templates.Notes = flag.Value.String()
+		}
+	}
+
+	if flag := cmd.Flags().Lookup("group-by"); flag != nil && flag.Changed {
+		cfg.Templates.GroupBy = flag.Value.String()
+	}
+
+	if render, _ := cmd.Flags().GetBool("render"); render {
+		cfg.K8sEngine.Render = true
+	}
+
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+		cfg.Cache.Enabled = false
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -8,22 +8,25 @@ import (
 	"log"
 	"os"
 
+	"github.com/alex-emery/release-notes/pkg/config"
 	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/alex-emery/release-notes/pkg/github"
 	"github.com/alex-emery/release-notes/pkg/notes"
-	jira "github.com/andygrunwald/go-jira/v2/cloud"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 func createNotesCmd(verbose *bool) *cobra.Command {
-	var jiraHost = new(string)
 	var privateKey = new(string)
+	var outputFormat = new(string)
+	var strict = new(bool)
+	var maxUntracked = new(int)
 	var notesCmd = &cobra.Command{
 		Use:   "notes",
 		Short: "Creates release notes for a repo",
 		Long: `Creates release notes for a repo, by fetching all commits between the two given tags.
-Jira tickets are extracted from the commit messages.
-These Jira tickets are then used to provide additional information in the generated notes..`,
+Tickets are extracted from the commit messages.
+These tickets are then fetched from the configured issue tracker (Jira, GitHub Issues or Linear) and used to provide additional information in the generated notes..`,
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) < 3 {
 				log.Fatal("not enough arguments: expected repo tag1 tag2")
@@ -42,57 +45,67 @@ These Jira tickets are then used to provide additional information in the genera
 				log.Fatal("failed to create logger", err)
 			}
 
-			jiraEmail := os.Getenv("JIRA_EMAIL")
-			if jiraEmail == "" {
-				logger.Fatal("JIRA_EMAIL not set")
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				logger.Fatal("failed to load config", zap.Error(err))
+			}
+
+			format, err := notes.ParseFormat(*outputFormat)
+			if err != nil {
+				logger.Fatal("invalid format", zap.Error(err))
 			}
 
-			jiraToken := os.Getenv("JIRA_TOKEN")
-			if jiraToken == "" {
-				logger.Fatal("JIRA_TOKEN not set")
+			trackers, err := newTrackers(logger, cfg)
+			if err != nil {
+				logger.Fatal("failed to create issue trackers", zap.Error(err))
 			}
 
-			gitAuth, err := git.New(logger, *privateKey)
+			gitAuth, err := git.New(logger, cfg, *privateKey)
 			if err != nil {
 				logger.Fatal("failed to create git auth", zap.Error(err))
 			}
 
+			// PRs are resolved through GitHub when possible, otherwise taken from the (#123) suffix of commit subjects
+			var pulls notes.PullRequestResolver
+			if ghToken := os.Getenv("GITHUB_TOKEN"); ghToken != "" {
+				pulls = github.New(logger, cfg, ghToken)
+			} else {
+				logger.Info("GITHUB_TOKEN not set, taking PRs from commit subjects")
+			}
+
 			repoName := args[0]
 			tag1 := args[1]
 			tag2 := args[2]
 
-			githubToken := os.Getenv("GITHUB_TOKEN")
-			if githubToken == "" {
-				log.Fatal("GITHUB_TOKEN not set")
+			releaseNote, err := notes.CreateReleaseNotesForRepo(ctx, logger, cfg, trackers, pulls, gitAuth, repoName, tag1, tag2)
+			if err != nil {
+				logger.Fatal("failed to collect the repo's commits", zap.String("repo", repoName), zap.Error(err))
 			}
 
-			tp := jira.BasicAuthTransport{
-				Username: jiraEmail,
-				APIToken: jiraToken,
-			}
+			release := notes.Release{Notes: []notes.ReleaseNote{releaseNote}}
+			checkUntracked(logger, release, *strict, *maxUntracked)
 
-			jiraClient, err := jira.NewClient(*jiraHost, tp.Client())
-			if err != nil {
-				logger.Fatal("failed to create jira client", zap.Error(err))
+			if format == notes.FormatMarkdown {
+				fmt.Println(notes.ReleaseNoteToString(logger, cfg, releaseNote))
+				return
 			}
 
-			releaseNote := notes.CreateReleaseNotesForRepo(ctx, logger, jiraClient, gitAuth, repoName, tag1, tag2)
+			doc := notes.NewDocument(cfg, release)
+			out, err := doc.Encode(format)
 			if err != nil {
-				logger.Fatal("failed to create release notes", zap.Error(err))
+				logger.Fatal("failed to encode release notes", zap.Error(err))
 			}
 
-			if releaseNote.Issues == nil {
-				logger.Fatal("no issues found")
-			}
-
-			releaseNoteString := notes.ReleaseNoteToString(logger, releaseNote)
-
-			fmt.Println(releaseNoteString)
+			fmt.Print(out)
 		},
 	}
 
-	notesCmd.Flags().StringVar(jiraHost, "jira-host", "https://adarga.atlassian.net", "the host of the jira instance")
 	notesCmd.Flags().StringVar(privateKey, "private-key", "", "the path to the private key to use for git authentication")
+	notesCmd.Flags().StringVar(outputFormat, "format", string(notes.FormatMarkdown), "output format: markdown, json or yaml")
+	notesCmd.Flags().String("group-by", config.GroupByIssue, "group the notes for each repo by issue or type, type splits commits by their conventional commit type")
+	notesCmd.Flags().String("template", "", "a notes template, or a directory containing notes.template and/or env.template, overriding the embedded ones")
+
+	addStrictFlags(notesCmd, strict, maxUntracked)
 
 	return notesCmd
 }
@@ -9,10 +9,10 @@ import (
 	"os"
 
 	"github.com/alex-emery/release-notes/internal/model/input"
+	"github.com/alex-emery/release-notes/pkg/config"
 	"github.com/alex-emery/release-notes/pkg/git"
 	"github.com/alex-emery/release-notes/pkg/github"
 	"github.com/alex-emery/release-notes/pkg/notes"
-	jira "github.com/andygrunwald/go-jira/v2/cloud"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -21,16 +21,18 @@ func createPrCmd(verbose *bool) *cobra.Command {
 	var sourceBranch = new(string)
 	var targetBranch = new(string)
 	var repoPath = new(string)
-	var jiraHost = new(string)
 	var privateKey = new(string)
 	var dryRun = new(bool)
+	var outputFormat = new(string)
+	var strict = new(bool)
+	var maxUntracked = new(int)
 
 	var prCmd = &cobra.Command{
 		Use:   "pr",
 		Short: "Creates a PR in k8s-engine based off the image diff between a branch and main.",
 		Long: `Creates a PR in the k8s-engine repo based on the diff between a branch and main.
 Images found in the diff are cloned into memory and fetched from GitHub.
-If a repo is found further information is gathered based off the commits between the tags, fetching tickets from Jira when possible.`,
+If a repo is found further information is gathered based off the commits between the tags, fetching tickets from the issue tracker when possible.`,
 		Run: func(cmd *cobra.Command, args []string) {
 
 			ctx := cmd.Context()
@@ -45,19 +47,23 @@ If a repo is found further information is gathered based off the commits between
 			if err != nil {
 				log.Fatal("failed to create logger", err)
 			}
-			gitAuth, err := git.New(logger, *privateKey)
+
+			cfg, err := loadConfig(cmd)
 			if err != nil {
-				logger.Fatal("failed to create git auth", zap.Error(err))
+				logger.Fatal("failed to load config", zap.Error(err))
 			}
 
-			jiraEmail := os.Getenv("JIRA_EMAIL")
-			if jiraEmail == "" {
-				logger.Fatal("JIRA_EMAIL not set")
+			format, err := notes.ParseFormat(*outputFormat)
+			if err != nil {
+				logger.Fatal("invalid format", zap.Error(err))
 			}
 
-			jiraToken := os.Getenv("JIRA_TOKEN")
-			if jiraToken == "" {
-				logger.Fatal("JIRA_TOKEN not set")
+			if format != notes.FormatMarkdown && !*dryRun {
+				logger.Fatal("--format can only be used with --dry-run, PRs are always markdown")
+			}
+			gitAuth, err := git.New(logger, cfg, *privateKey)
+			if err != nil {
+				logger.Fatal("failed to create git auth", zap.Error(err))
 			}
 
 			ghToken := os.Getenv("GITHUB_TOKEN")
@@ -65,28 +71,62 @@ If a repo is found further information is gathered based off the commits between
 				logger.Fatal("GITHUB_TOKEN not set")
 			}
 
-			tp := jira.BasicAuthTransport{
-				Username: jiraEmail,
-				APIToken: jiraToken,
+			ghClient := github.New(logger, cfg, ghToken)
+
+			trackers, err := newTrackers(logger, cfg)
+			if err != nil {
+				logger.Fatal("failed to create issue trackers", zap.Error(err))
 			}
 
-			jiraClient, err := jira.NewClient(*jiraHost, tp.Client())
+			repo, err := notes.OpenK8sEngine(logger, gitAuth, *repoPath)
 			if err != nil {
-				logger.Fatal("failed to create jira client", zap.Error(err))
+				logger.Fatal("failed to open k8s-engine", zap.Error(err))
+			}
+
+			if *targetBranch == "" {
+				if *targetBranch, err = notes.DefaultTarget(logger, repo); err != nil {
+					logger.Fatal("failed to default the target branch", zap.Error(err))
+				}
 			}
 
-			// pass pointers to the branch because set it to the head ref if it's empty
-			notes, err := notes.CreateReleaseNotesFromK8sEngine(ctx, logger, gitAuth, jiraClient, *repoPath, *sourceBranch, targetBranch)
+			// the PR is opened between the branches GitHub knows, checked before any notes are created
+			head, base := *targetBranch, *sourceBranch
+			if !*dryRun {
+				if head, err = git.BranchName(repo, *targetBranch); err != nil {
+					logger.Fatal("can't open a PR from --target, use --dry-run to compare it", zap.Error(err))
+				}
+
+				if base, err = git.BranchName(repo, *sourceBranch); err != nil {
+					logger.Fatal("can't open a PR into --source, use --dry-run to compare it", zap.Error(err))
+				}
+			}
+
+			release, err := notes.CreateReleaseNotesFromK8sEngine(ctx, logger, cfg, gitAuth, trackers, ghClient, repo, *sourceBranch, *targetBranch)
 			if err != nil {
 				logger.Fatal("failed to create release notes", zap.Error(err))
 			}
 
-			if *dryRun {
-				fmt.Println(notes)
+			checkUntracked(logger, release, *strict, *maxUntracked)
+
+			if *dryRun && format != notes.FormatMarkdown {
+				out, err := notes.NewDocument(cfg, release).Encode(format)
+				if err != nil {
+					logger.Fatal("failed to encode release notes", zap.Error(err))
+				}
+
+				fmt.Print(out)
 				return
 			}
 
-			ghClient := github.New(logger, ghToken)
+			body, err := release.Markdown(logger, cfg)
+			if err != nil {
+				logger.Fatal("failed to render release notes", zap.Error(err))
+			}
+
+			if *dryRun {
+				fmt.Println(body)
+				return
+			}
 
 			// ask the user to enter a title
 			title, err := input.Run("Enter a title for the PR: ", "title")
@@ -98,19 +138,24 @@ If a repo is found further information is gathered based off the commits between
 				logger.Fatal("title cannot be empty")
 			}
 
-			if err = ghClient.CreatePR(ctx, *targetBranch, *sourceBranch, title, notes); err != nil {
+			if err = ghClient.CreatePR(ctx, head, base, title, body); err != nil {
 				logger.Fatal("failed to create PR", zap.Error(err))
 			}
 		},
 	}
 
-	prCmd.Flags().StringVarP(sourceBranch, "source", "s", "main", "source branch")
-	prCmd.Flags().StringVarP(targetBranch, "target", "t", "", "target branch, defaults to current branch if not specified")
+	prCmd.Flags().StringVarP(sourceBranch, "source", "s", "main", "source branch, remote branch (origin/main), tag or SHA")
+	prCmd.Flags().StringVarP(targetBranch, "target", "t", "", "target branch, remote branch, tag or SHA, defaults to the current branch if not specified")
 	prCmd.Flags().StringVar(repoPath, "path", ".", "path to the local k8s-engine repo")
-	prCmd.Flags().StringVar(jiraHost, "jira-host", "https://adarga.atlassian.net", "the host of the jira instance")
 	prCmd.Flags().BoolVar(dryRun, "dry-run", false, "disables PR creation in GitHub")
+	prCmd.Flags().StringVar(outputFormat, "format", string(notes.FormatMarkdown), "output format for --dry-run: markdown, json or yaml")
+	prCmd.Flags().String("group-by", config.GroupByIssue, "group the notes for each repo by issue or type, type splits commits by their conventional commit type")
+	prCmd.Flags().String("template", "", "a notes template, or a directory containing notes.template and/or env.template, overriding the embedded ones")
+
+	prCmd.Flags().Bool("render", false, "build the overlays with kustomize to find images set in bases, components, patches or replacements")
 
 	prCmd.Flags().StringVar(privateKey, "private-key", "", "path to the private key")
+	addStrictFlags(prCmd, strict, maxUntracked)
 
 	return prCmd
 }
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/alex-emery/release-notes/internal/wizard"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/alex-emery/release-notes/pkg/github"
+	"github.com/alex-emery/release-notes/pkg/notes"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func createPromoteCmd(verbose *bool) *cobra.Command {
+	var repoPath = new(string)
+	var privateKey = new(string)
+	var from = new(string)
+	var to = new(string)
+	var namespace = new(string)
+	var image = new(string)
+	var dryRun = new(bool)
+	var noNotes = new(bool)
+
+	promoteCmd := &cobra.Command{
+		Use:   "promote",
+		Short: "Promotes the image tags of one environment to another",
+		Long: `Promotes the image tags of one environment to another, i.e stage to prod.
+Every namespace in both environments is compared, the plan and the release notes for each image are printed
+and the tags are then updated in the target environment. Images and namespaces only found in the source are left out.
+	release-notes promote --from stage --to prod
+	release-notes promote --from stage --to prod --namespace 'wb-*' --image 'adarga/*' --dry-run`,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+			var logger *zap.Logger
+			var err error
+			if *verbose {
+				logger, err = zap.NewDevelopment()
+			} else {
+				logger, err = zap.NewProduction()
+			}
+
+			if err != nil {
+				log.Fatal("failed to create logger", err)
+			}
+
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				logger.Fatal("failed to load config", zap.Error(err))
+			}
+
+			if *from == "" || *to == "" || *from == *to {
+				logger.Fatal("--from and --to must be two different environments")
+			}
+
+			for _, env := range []string{*from, *to} {
+				if !slices.Contains(cfg.K8sEngine.Environments, env) {
+					logger.Fatal("unknown environment, expected one of k8sEngine.environments", zap.String("environment", env), zap.Strings("environments", cfg.K8sEngine.Environments))
+				}
+			}
+
+			plan, err := wizard.PlanPromotion(*repoPath, *from, *to, *namespace, *image)
+			if err != nil {
+				logger.Fatal("failed to plan promotion", zap.Error(err))
+			}
+
+			if len(plan) == 0 {
+				fmt.Printf("%s is up to date with %s\n", *to, *from)
+				return
+			}
+
+			diffs := make([]git.ImageDiff, 0, len(plan))
+			for _, promotion := range plan {
+				diffs = append(diffs, git.ImageDiff{
+					Path:        promotion.Path(),
+					Environment: promotion.Environment,
+					Namespace:   promotion.Namespace,
+					Name:        promotion.Image,
+					NewName1:    promotion.NewName,
+					NewName2:    promotion.NewName,
+					Kind:        git.ImageRetagged,
+					Tag1:        promotion.Tag,
+					Tag2:        promotion.NewTag,
+				})
+			}
+
+			release := notes.Release{Images: diffs}
+			if !*noNotes {
+				trackers, err := newTrackers(logger, cfg)
+				if err != nil {
+					logger.Fatal("failed to create issue trackers", zap.Error(err))
+				}
+
+				gitAuth, err := git.New(logger, cfg, *privateKey)
+				if err != nil {
+					logger.Fatal("failed to create git auth", zap.Error(err))
+				}
+
+				var pulls notes.PullRequestResolver
+				if ghToken := os.Getenv("GITHUB_TOKEN"); ghToken != "" {
+					pulls = github.New(logger, cfg, ghToken)
+				}
+
+				release = notes.CreateReleaseNotesForImages(ctx, logger, cfg, gitAuth, trackers, pulls, diffs)
+			}
+
+			fmt.Println(release.NotesMarkdown(logger, cfg))
+
+			if *dryRun {
+				return
+			}
+
+			// every file is checked before any is written, so a failure doesn't leave the environment half promoted
+			for _, promotion := range plan {
+				if _, _, err := wizard.PreviewImageVersion(*repoPath, promotion.Environment, promotion.Namespace, promotion.Image, promotion.NewTag); err != nil {
+					logger.Fatal("can't update image, nothing was written", zap.String("path", promotion.Path()), zap.Error(err))
+				}
+			}
+
+			for _, promotion := range plan {
+				if err := wizard.UpdateImageVersion(*repoPath, promotion.Environment, promotion.Namespace, promotion.Image, promotion.NewTag); err != nil {
+					logger.Fatal("failed to update image", zap.String("path", promotion.Path()), zap.Error(err))
+				}
+
+				fmt.Printf("updated %s: %s %s → %s\n", promotion.Path(), promotion.Image, promotion.Tag, promotion.NewTag)
+			}
+		},
+	}
+
+	promoteCmd.Flags().StringVar(repoPath, "path", ".", "path to the local k8s-engine repo")
+	promoteCmd.Flags().StringVar(from, "from", "", "environment to take the tags from, i.e stage")
+	promoteCmd.Flags().StringVar(to, "to", "", "environment to update, i.e prod")
+	promoteCmd.Flags().StringVar(namespace, "namespace", "*", "namespaces to promote, a glob such as 'wb-*' matches several")
+	promoteCmd.Flags().StringVar(image, "image", "", "images to promote, a glob such as 'adarga/*' matches several, defaults to every image")
+	promoteCmd.Flags().BoolVar(dryRun, "dry-run", false, "print the plan without updating any files")
+	promoteCmd.Flags().BoolVar(noNotes, "no-notes", false, "only print the plan, without fetching release notes")
+	promoteCmd.Flags().StringVar(privateKey, "private-key", "", "path to the private key")
+
+	return promoteCmd
+}
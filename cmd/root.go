@@ -6,6 +6,7 @@ package cmd
 import (
 	"os"
 
+	"github.com/alex-emery/release-notes/pkg/config"
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 )
@@ -19,12 +20,18 @@ func createRootCmd() *cobra.Command {
 	}
 
 	rootCmd.PersistentFlags().BoolVar(verbose, "verbose", false, "enable verbose logging")
+	rootCmd.PersistentFlags().String("config", "", "path to the config file, defaults to "+config.FileName+" in the repo root or $XDG_CONFIG_HOME/release-notes/config.yaml")
+	rootCmd.PersistentFlags().String("github-org", "", "the GitHub organisation, overrides github.org in the config")
+	rootCmd.PersistentFlags().String("jira-host", "", "the host of the jira instance, overrides jira.host in the config")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "clone repos into memory instead of using the on disk cache")
 
 	_ = godotenv.Load()
 
 	rootCmd.AddCommand(createPrCmd(verbose))
 	rootCmd.AddCommand(createNotesCmd(verbose))
 	rootCmd.AddCommand(createUpdateCmd())
+	rootCmd.AddCommand(createPromoteCmd(verbose))
+	rootCmd.AddCommand(createCacheCmd())
 	return rootCmd
 
 }
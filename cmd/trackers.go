@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/tracker"
+	"go.uber.org/zap"
+)
+
+// newTrackers creates the issue trackers referenced by the config,
+// credentials are read from the environment only for the trackers in use.
+func newTrackers(logger *zap.Logger, cfg *config.Config) (tracker.Trackers, error) {
+	trackers := tracker.Trackers{}
+	for _, name := range cfg.TrackerNames() {
+		switch name {
+		case config.TrackerJira:
+			jiraEmail := os.Getenv("JIRA_EMAIL")
+			if jiraEmail == "" {
+				return nil, fmt.Errorf("JIRA_EMAIL not set")
+			}
+
+			jiraToken := os.Getenv("JIRA_TOKEN")
+			if jiraToken == "" {
+				return nil, fmt.Errorf("JIRA_TOKEN not set")
+			}
+
+			jiraTracker, err := tracker.NewJira(logger, cfg.Jira.Host, jiraEmail, jiraToken, cfg.Jira.BatchSize)
+			if err != nil {
+				return nil, err
+			}
+			trackers[name] = jiraTracker
+
+		case config.TrackerGitHub:
+			ghToken := os.Getenv("GITHUB_TOKEN")
+			if ghToken == "" {
+				return nil, fmt.Errorf("GITHUB_TOKEN not set")
+			}
+
+			trackers[name] = tracker.NewGitHub(logger, cfg.GitHub.Org, ghToken)
+
+		case config.TrackerLinear:
+			linearKey := os.Getenv("LINEAR_API_KEY")
+			if linearKey == "" {
+				return nil, fmt.Errorf("LINEAR_API_KEY not set")
+			}
+
+			trackers[name] = tracker.NewLinear(logger, cfg.Linear.Workspace, linearKey)
+		}
+	}
+
+	return trackers, nil
+}
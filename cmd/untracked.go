@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/alex-emery/release-notes/pkg/notes"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func addStrictFlags(cmd *cobra.Command, strict *bool, maxUntracked *int) {
+	cmd.Flags().BoolVar(strict, "strict", false, "fail when there are more untracked commits than --max-untracked")
+	cmd.Flags().IntVar(maxUntracked, "max-untracked", 0, "the number of untracked commits allowed with --strict")
+}
+
+// checkUntracked exits when strict and the release has more untracked commits than allowed.
+func checkUntracked(logger *zap.Logger, release notes.Release, strict bool, maxUntracked int) {
+	count := release.UntrackedCount()
+	if count == 0 {
+		return
+	}
+
+	if strict && count > maxUntracked {
+		logger.Fatal("too many commits without a ticket", zap.Int("untracked", count), zap.Int("max", maxUntracked))
+	}
+
+	logger.Warn("found commits without a ticket", zap.Int("untracked", count))
+}
@@ -4,28 +4,194 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"io"
+
 	"github.com/alex-emery/release-notes/internal/wizard"
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/alex-emery/release-notes/pkg/notes"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
+// updateOptions select the kustomizations to update without the wizard.
+type updateOptions struct {
+	env           string
+	namespace     string
+	allNamespaces bool
+	image         string
+	tag           string
+	dryRun        bool
+}
+
+// interactive is true when none of the flags selecting kustomizations are set, any of them
+// means the update is scripted so a missing one is an error rather than opening the wizard.
+func (o updateOptions) interactive() bool {
+	return o.env == "" && o.namespace == "" && !o.allNamespaces && o.image == "" && o.tag == "" && !o.dryRun
+}
+
 func createUpdateCmd() *cobra.Command {
 	var repoPath = new(string)
+	var privateKey = new(string)
+	var opts = &updateOptions{}
 	// updateCmd represents the update command
 	updateCmd := &cobra.Command{
 		Use:   "update",
-		Short: "Interactively update images in the k8s engine repo",
+		Short: "Update images in the k8s engine repo, interactively unless an image is given",
+		Long: `Update images in the k8s engine repo.
+Without --env, --namespace, --all-namespaces, --image, --tag or --dry-run a wizard walks through picking the environment, namespace, image and version,
+then shows the diff and release notes before anything is written.
+With --env, --image and --tag the image is updated in every matching namespace, --env and --namespace accept globs.
+	release-notes update --env prod --namespace wb-lfqa --image some-image --tag 1.4.0
+	release-notes update --env '*' --all-namespaces --image some-image --tag 1.4.0 --dry-run`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			m := wizard.NewModel(*repoPath)
-			p := tea.NewProgram(m)
-			if _, err := p.Run(); err != nil {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
 				return err
 			}
-			return nil
+
+			if opts.interactive() {
+				// shared by the background commands, so they take the same locks on the repo cache.
+				// Logging is discarded as it would draw over the wizard.
+				gitAuth, err := git.New(zap.NewNop(), cfg, *privateKey)
+				if err != nil {
+					return fmt.Errorf("failed to create git auth: %w", err)
+				}
+
+				m := wizard.NewModel(cfg, *repoPath, tagLister(cfg, gitAuth), notesRenderer(cfg, gitAuth))
+				p := tea.NewProgram(m)
+				if _, err := p.Run(); err != nil {
+					return err
+				}
+				return nil
+			}
+
+			// a missing image isn't a usage error, scripts only need the message
+			cmd.SilenceUsage = true
+			return runUpdate(cmd.OutOrStdout(), cfg, *repoPath, *opts)
 		},
 	}
 
 	updateCmd.Flags().StringVar(repoPath, "path", ".", "path to the local k8s-engine repo")
+	updateCmd.Flags().StringVar(&opts.env, "env", "", "environment to update, a glob such as '*' matches several")
+	updateCmd.Flags().StringVar(&opts.namespace, "namespace", "", "namespace to update, a glob such as 'wb-*' matches several")
+	updateCmd.Flags().BoolVar(&opts.allNamespaces, "all-namespaces", false, "update every namespace in the environment that uses the image")
+	updateCmd.Flags().StringVar(&opts.image, "image", "", "name of the image to update, as in the kustomization's images")
+	updateCmd.Flags().StringVar(&opts.tag, "tag", "", "the new tag")
+	updateCmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "print a diff of each file instead of writing it")
+	updateCmd.Flags().StringVar(privateKey, "private-key", "", "path to the private key, used to fetch the tags suggested by the wizard")
 
 	return updateCmd
 }
+
+// tagLister clones the repo an image is built from to suggest its tags in the wizard.
+func tagLister(cfg *config.Config, gitAuth *git.Auth) wizard.TagLister {
+	return func(image string) ([]git.TagInfo, error) {
+		repoName := git.ExtractRepoName(cfg.Images.RepoPrefix, image)
+		if repoName == "" {
+			return nil, fmt.Errorf("%s is not under %s", image, cfg.Images.RepoPrefix)
+		}
+
+		r, err := gitAuth.CloneRepo(repoName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone %s: %w", repoName, err)
+		}
+
+		return git.SemverTags(r, cfg.TagsFor(repoName).Prefixes)
+	}
+}
+
+// notesRenderer creates the release notes between the current and new tag, shown before the wizard
+// writes anything. Logging is discarded as it would draw over the wizard.
+func notesRenderer(cfg *config.Config, gitAuth *git.Auth) wizard.NotesRenderer {
+	return func(image, tag1, tag2 string) (string, error) {
+		repoName := git.ExtractRepoName(cfg.Images.RepoPrefix, image)
+		if repoName == "" {
+			return "", fmt.Errorf("%s is not under %s", image, cfg.Images.RepoPrefix)
+		}
+
+		logger := zap.NewNop()
+		trackers, err := newTrackers(logger, cfg)
+		if err != nil {
+			return "", err
+		}
+
+		note, err := notes.CreateReleaseNotesForRepo(context.Background(), logger, cfg, trackers, nil, gitAuth, repoName, tag1, tag2)
+		if err != nil {
+			return "", err
+		}
+
+		return note.String(cfg)
+	}
+}
+
+// runUpdate sets the image's tag in every matching kustomization, printing what changed.
+// It fails without writing anything when no kustomization uses the image, or any of them can't be
+// updated, i.e the image has no newTag.
+func runUpdate(out io.Writer, cfg *config.Config, repoPath string, opts updateOptions) error {
+	if opts.env == "" || opts.image == "" || opts.tag == "" {
+		return fmt.Errorf("--env, --image and --tag are all required")
+	}
+
+	if opts.allNamespaces {
+		if opts.namespace != "" {
+			return fmt.Errorf("--namespace and --all-namespaces can't be used together")
+		}
+		opts.namespace = "*"
+	}
+
+	if opts.namespace == "" {
+		return fmt.Errorf("--namespace or --all-namespaces is required")
+	}
+
+	targets, err := wizard.FindTargets(repoPath, cfg.K8sEngine.Environments, opts.env, opts.namespace, opts.image)
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: %s in environment %s, namespace %s", wizard.ErrImageNotFound, opts.image, opts.env, opts.namespace)
+	}
+
+	// every file is checked before any is written, so a failure doesn't leave the update half done
+	pending := []wizard.Target{}
+	for _, target := range targets {
+		if target.Tag == opts.tag {
+			fmt.Fprintf(out, "unchanged %s: %s is already %s\n", target.Path(), target.Image, target.Tag)
+			continue
+		}
+
+		before, after, err := wizard.PreviewImageVersion(repoPath, target.Environment, target.Namespace, target.Image, opts.tag)
+		if err != nil {
+			return fmt.Errorf("can't update %s, nothing was written: %w", target.Path(), err)
+		}
+
+		if opts.dryRun {
+			diff, err := wizard.UnifiedDiff(target.Path(), before, after)
+			if err != nil {
+				return fmt.Errorf("failed to diff %s: %w", target.Path(), err)
+			}
+
+			fmt.Fprint(out, diff)
+		}
+
+		pending = append(pending, target)
+	}
+
+	if opts.dryRun {
+		return nil
+	}
+
+	for _, target := range pending {
+		if err := wizard.UpdateImageVersion(repoPath, target.Environment, target.Namespace, target.Image, opts.tag); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "updated %s: %s %s → %s\n", target.Path(), target.Image, target.Tag, opts.tag)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -64,3 +65,130 @@ func New(title string, items []string) list.Model {
 
 	return l
 }
+
+// Option is an item of a multi-select list.
+type Option struct {
+	Value    string
+	Selected bool
+}
+
+func (o Option) FilterValue() string { return o.Value }
+
+// multiDelegate renders a checkbox for each option, space toggles the current option and a toggles every visible option.
+type multiDelegate struct{}
+
+func (d multiDelegate) Height() int  { return 1 }
+func (d multiDelegate) Spacing() int { return 0 }
+func (d multiDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	switch keyMsg.String() {
+	case " ":
+		current, ok := m.SelectedItem().(Option)
+		if !ok {
+			return nil
+		}
+
+		for i, item := range m.Items() {
+			if option := item.(Option); option.Value == current.Value {
+				option.Selected = !option.Selected
+				return m.SetItem(i, option)
+			}
+		}
+	case "a":
+		// selects every option on screen, only the matching ones when filtered,
+		// unless they're all already selected
+		visible := map[string]bool{}
+		all := true
+		for _, item := range m.VisibleItems() {
+			option := item.(Option)
+			visible[option.Value] = true
+			all = all && option.Selected
+		}
+
+		cmds := []tea.Cmd{}
+		for i, item := range m.Items() {
+			option := item.(Option)
+			if !visible[option.Value] {
+				continue
+			}
+
+			option.Selected = !all
+			cmds = append(cmds, m.SetItem(i, option))
+		}
+
+		return tea.Batch(cmds...)
+	}
+
+	return nil
+}
+
+func (d multiDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	o, ok := listItem.(Option)
+	if !ok {
+		return
+	}
+
+	check := "[ ]"
+	if o.Selected {
+		check = "[x]"
+	}
+
+	str := fmt.Sprintf("%s %d. %s", check, index+1, o.Value)
+
+	fn := itemStyle.Render
+	if index == m.Index() {
+		fn = func(s ...string) string {
+			return selectedItemStyle.Render("> " + strings.Join(s, " "))
+		}
+	}
+
+	fmt.Fprint(w, fn(str))
+}
+
+// NewMulti creates a list where several items can be selected, see Selected.
+func NewMulti(title string, items []string) list.Model {
+	options := make([]list.Item, 0, len(items))
+	for _, item := range items {
+		options = append(options, Option{Value: item})
+	}
+
+	l := New(title, nil)
+	l.SetDelegate(multiDelegate{})
+	l.SetItems(options)
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "toggle")),
+			key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "all")),
+		}
+	}
+
+	return l
+}
+
+// Selected returns the values of the selected options in a list created by NewMulti,
+// or the item under the cursor when nothing has been selected.
+func Selected(l list.Model) []string {
+	selected := []string{}
+	for _, item := range l.Items() {
+		if option, ok := item.(Option); ok && option.Selected {
+			selected = append(selected, option.Value)
+		}
+	}
+
+	if len(selected) > 0 {
+		return selected
+	}
+
+	switch item := l.SelectedItem().(type) {
+	case Option:
+		return []string{item.Value}
+	case Item:
+		return []string{string(item)}
+	}
+
+	return selected
+}
@@ -0,0 +1,164 @@
+package suggest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxSuggestions is how many matching suggestions are shown at once.
+const maxSuggestions = 10
+
+var (
+	itemStyle         = lipgloss.NewStyle().PaddingLeft(4)
+	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("170"))
+	descriptionStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	errorStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	helpStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+type Suggestion struct {
+	Value       string
+	Description string
+}
+
+// Model is a text input with a list of suggestions filtered by what's been typed.
+// Up and down pick a suggestion, tab copies it into the input, enter is handled by the parent
+// and esc or ctrl+c quit.
+type Model struct {
+	title       string
+	textInput   textinput.Model
+	suggestions []Suggestion
+	// cursor is the index of the picked suggestion within the matches, -1 when the typed text is used.
+	cursor  int
+	status  string
+	errText string
+}
+
+func New(title, placeholder string) Model {
+	ti := textinput.New()
+	ti.Focus()
+	ti.Width = 200
+	ti.Placeholder = placeholder
+	return Model{
+		title:     title,
+		textInput: ti,
+		cursor:    -1,
+	}
+}
+
+func (m Model) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// SetSuggestions replaces the suggestions, in the order they're shown.
+func (m *Model) SetSuggestions(suggestions []Suggestion) {
+	m.suggestions = suggestions
+	m.cursor = -1
+}
+
+// SetStatus shows a message below the input, i.e while the suggestions are loading.
+func (m *Model) SetStatus(status string) {
+	m.status = status
+}
+
+// SetError shows an error below the input, it's cleared on the next key press.
+func (m *Model) SetError(err string) {
+	m.errText = err
+}
+
+// Value is the picked suggestion, or the typed text when none is picked.
+func (m Model) Value() string {
+	matches := m.matches()
+	if m.cursor >= 0 && m.cursor < len(matches) {
+		return matches[m.cursor].Value
+	}
+
+	return m.textInput.Value()
+}
+
+// matches are the suggestions containing the typed text.
+func (m Model) matches() []Suggestion {
+	typed := m.textInput.Value()
+	matches := []Suggestion{}
+	for _, s := range m.suggestions {
+		if strings.Contains(s.Value, typed) {
+			matches = append(matches, s)
+		}
+	}
+
+	return matches
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		m.errText = ""
+
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyEsc:
+			return m, tea.Quit
+		case tea.KeyUp:
+			if m.cursor >= 0 {
+				m.cursor--
+			}
+			return m, nil
+		case tea.KeyDown:
+			if m.cursor < len(m.matches())-1 && m.cursor < maxSuggestions-1 {
+				m.cursor++
+			}
+			return m, nil
+		case tea.KeyTab:
+			m.textInput.SetValue(m.Value())
+			m.textInput.CursorEnd()
+			m.cursor = -1
+			return m, nil
+		}
+	}
+
+	before := m.textInput.Value()
+	m.textInput, cmd = m.textInput.Update(msg)
+	if m.textInput.Value() != before {
+		m.cursor = -1
+	}
+
+	return m, cmd
+}
+
+func (m Model) View() string {
+	view := strings.Builder{}
+	fmt.Fprintf(&view, "%s\n\n%s\n\n", m.title, m.textInput.View())
+
+	for i, s := range m.matches() {
+		if i == maxSuggestions {
+			break
+		}
+
+		line := s.Value
+		if s.Description != "" {
+			line += "  " + descriptionStyle.Render(s.Description)
+		}
+
+		if i == m.cursor {
+			view.WriteString(selectedItemStyle.Render("> "+line) + "\n")
+		} else {
+			view.WriteString(itemStyle.Render(line) + "\n")
+		}
+	}
+
+	if m.status != "" {
+		view.WriteString(helpStyle.Render(m.status) + "\n")
+	}
+
+	if m.errText != "" {
+		view.WriteString(errorStyle.Render(m.errText) + "\n")
+	}
+
+	view.WriteString("\n" + helpStyle.Render("(↑/↓ to pick a suggestion, tab to complete, enter to confirm, esc to quit)") + "\n")
+
+	return view.String()
+}
@@ -2,11 +2,18 @@ package wizard
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
+	"github.com/Masterminds/semver"
 	"github.com/alex-emery/release-notes/internal/model/filter"
-	"github.com/alex-emery/release-notes/internal/model/input"
+	"github.com/alex-emery/release-notes/internal/model/suggest"
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"sigs.k8s.io/kustomize/api/types"
 )
 
@@ -17,26 +24,75 @@ const (
 	NamespacePage
 	ImagePage
 	VersionPage
+	ConfirmPage
 	DonePage
 )
 
+// TagLister returns the git tags of the repo an image is built from, newest first.
+type TagLister func(image string) ([]git.TagInfo, error)
+
+// tagsMsg carries the tags listed for the version page.
+type tagsMsg struct {
+	tags []git.TagInfo
+	err  error
+}
+
+// NotesRenderer renders the release notes of an image between two tags.
+type NotesRenderer func(image, tag1, tag2 string) (string, error)
+
+// notesMsg carries the release notes for the confirmation page, version is the one they were rendered for.
+type notesMsg struct {
+	version string
+	notes   string
+}
+
+var (
+	headerStyle  = lipgloss.NewStyle().Bold(true)
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	hunkStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	helpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+// image tags as allowed by the OCI distribution spec
+var imageTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
 type Model struct {
-	step         Step
-	list         list.Model
-	input        input.Model
-	basepath     string
-	environment  string
-	namespace    string
-	image        string
+	step        Step
+	list        list.Model
+	input       suggest.Model
+	listTags    TagLister
+	renderNotes NotesRenderer
+	basepath    string
+	environment string
+	namespaces  []string
+	images      []string
+	// kustFiles are the kustomizations of the selected namespaces, keyed by namespace.
+	kustFiles map[string]*types.Kustomization
+	// knownTags are the versions of the image's git tags, nil when they couldn't be listed.
+	knownTags []git.TagInfo
+	// fetchingTags is set until the tags arrive, the version can't be checked before then.
+	fetchingTags bool
 	imageVersion string
-	kustFile     *types.Kustomization
-	err          error
+	// diff is the pending change to every target, shown for confirmation.
+	diff  string
+	notes string
+	// confirmView scrolls the diff and notes when they're taller than the terminal.
+	confirmView viewport.Model
+	results     []Result
+	aborted     bool
+	err         error
 }
 
-func NewModel(basepath string) Model {
+// NewModel creates the wizard, listTags suggests versions for an image and renderNotes shows
+// what's being deployed before anything is written, either may be nil.
+func NewModel(cfg *config.Config, basepath string, listTags TagLister, renderNotes NotesRenderer) Model {
 	return Model{
-		basepath: basepath,
-		list:     filter.New("Select an environment", []string{"dev", "stage", "prod"}),
+		basepath:    basepath,
+		listTags:    listTags,
+		renderNotes: renderNotes,
+		list:        filter.New("Select an environment", cfg.K8sEngine.Environments),
+		confirmView: viewport.New(0, 0),
 	}
 }
 
@@ -47,7 +103,26 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// leave room for the help line below the diff
+		m.confirmView.Width = msg.Width
+		m.confirmView.Height = max(msg.Height-2, 1)
+		m.confirmView.SetContent(m.confirmContent())
+	case tagsMsg:
+		m.setSuggestions(msg)
+		return m, nil
+	case notesMsg:
+		// notes for a version that's since been changed are dropped
+		if m.step == ConfirmPage && msg.version == m.imageVersion {
+			m.notes = msg.notes
+			m.confirmView.SetContent(m.confirmContent())
+		}
+		return m, nil
 	case tea.KeyMsg:
+		if m.step == ConfirmPage {
+			return m.confirm(msg)
+		}
+
 		switch msg.String() {
 		case "enter":
 			switch m.step {
@@ -58,55 +133,94 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.err = fmt.Errorf("failed to get namespaces: %w", err)
 				}
 
-				m.list = filter.New("Select a namespace", namespaces)
+				m.list = filter.NewMulti("Select namespaces", namespaces)
 				m.step = NamespacePage
 				return m, nil
 
 			case NamespacePage:
-				m.namespace = string(m.list.SelectedItem().(filter.Item))
-				kustFile, err := OpenKustomization(m.basepath, string(m.environment), string(m.namespace))
-				if err != nil {
-					m.err = fmt.Errorf("failed to open kustomization file: %w", err)
-				}
+				m.namespaces = filter.Selected(m.list)
+				m.kustFiles = map[string]*types.Kustomization{}
 
-				m.kustFile = kustFile
+				// every image used by any of the namespaces, in the order they're first seen
+				images := []string{}
+				seen := map[string]bool{}
+				for _, ns := range m.namespaces {
+					kustFile, err := OpenKustomization(m.basepath, string(m.environment), ns)
+					if err != nil {
+						m.err = fmt.Errorf("failed to open kustomization file: %w", err)
+						return m, nil
+					}
 
-				images := make([]string, 0, len(kustFile.Images))
-				for _, image := range kustFile.Images {
-					images = append(images, image.Name)
+					m.kustFiles[ns] = kustFile
+					for _, image := range kustFile.Images {
+						if !seen[image.Name] {
+							seen[image.Name] = true
+							images = append(images, image.Name)
+						}
+					}
 				}
 
-				m.list = filter.New("Select an image", images)
+				m.list = filter.NewMulti("Select images", images)
 				m.step = ImagePage
 				return m, nil
 			case ImagePage:
-				m.image = string(m.list.SelectedItem().(filter.Item))
+				m.images = filter.Selected(m.list)
 
+				// the current version is only suggested when every target has the same one
 				originalVersion := ""
-				for _, image := range m.kustFile.Images {
-					if image.Name == m.image {
-						originalVersion = image.NewTag
+				for i, target := range m.targets() {
+					if i > 0 && target.Tag != originalVersion {
+						originalVersion = ""
 						break
 					}
+					originalVersion = target.Tag
 				}
 
-				m.input = input.New("Enter a new version", originalVersion)
+				m.input = suggest.New("Enter a new version", originalVersion)
 				m.step = VersionPage
-				return m, nil
+
+				// tags are per repo, so versions are only suggested for a single image
+				if len(m.images) != 1 || m.listTags == nil {
+					return m, nil
+				}
+
+				m.input.SetStatus("fetching tags…")
+				m.fetchingTags = true
+				listTags, image := m.listTags, m.imageName(m.images[0])
+				return m, func() tea.Msg {
+					tags, err := listTags(image)
+					return tagsMsg{tags: tags, err: err}
+				}
 			case VersionPage:
-				m.imageVersion = m.input.Value()
-				err := UpdateImageVersion(m.basepath, m.environment, m.namespace, m.image, m.imageVersion)
-				if err != nil {
-					m.err = fmt.Errorf("failed to update image version: %w", err)
+				if m.fetchingTags {
+					m.input.SetError("still fetching tags, the version can't be checked yet")
+					return m, nil
 				}
 
-				m.step = DonePage
+				version := m.input.Value()
+				if err := m.validateVersion(version); err != nil {
+					m.input.SetError(err.Error())
+					return m, nil
+				}
+
+				m.imageVersion = version
+				m.diff = m.previewTargets()
+				m.notes = ""
+				m.step = ConfirmPage
+
+				cmd := m.notesCmd()
+				if cmd != nil {
+					m.notes = "generating release notes…"
+				}
+				m.confirmView.SetContent(m.confirmContent())
+				m.confirmView.GotoTop()
+				return m, cmd
 			}
 		}
 	}
 	if m.step == VersionPage {
 		newModel, cmd := m.input.Update(msg)
-		m.input = newModel.(input.Model)
+		m.input = newModel.(suggest.Model)
 		return m, cmd
 	}
 
@@ -114,13 +228,260 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// confirm handles the keys of the confirmation page, nothing is written unless the update is confirmed.
+func (m Model) confirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "enter":
+		m.results = UpdateTargets(m.basepath, m.targets(), m.imageVersion)
+		m.step = DonePage
+		return m, tea.Quit
+	case "b", "esc":
+		m.step = VersionPage
+		return m, nil
+	case "q", "ctrl+c":
+		m.aborted = true
+		m.step = DonePage
+		return m, tea.Quit
+	}
+
+	// anything else scrolls
+	var cmd tea.Cmd
+	m.confirmView, cmd = m.confirmView.Update(msg)
+	return m, cmd
+}
+
+// confirmContent is the diff of every target followed by the release notes.
+func (m Model) confirmContent() string {
+	if m.notes == "" {
+		return m.diff
+	}
+
+	return m.diff + "\n" + m.notes
+}
+
+// previewTargets is the coloured diff of every target, or why it can't be updated.
+func (m Model) previewTargets() string {
+	view := strings.Builder{}
+	for _, target := range m.targets() {
+		before, after, err := PreviewImageVersion(m.basepath, target.Environment, target.Namespace, target.Image, m.imageVersion)
+		if err == nil {
+			var diff string
+			if diff, err = UnifiedDiff(target.Path(), before, after); err == nil {
+				view.WriteString(colourDiff(diff))
+				continue
+			}
+		}
+
+		view.WriteString(removedStyle.Render(fmt.Sprintf("✗ %s: %s: %s", target.Path(), target.Image, err)) + "\n")
+	}
+
+	return view.String()
+}
+
+// colourDiff colours a unified diff like git diff does.
+func colourDiff(diff string) string {
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		text := strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(text, "+++"), strings.HasPrefix(text, "---"):
+			text = headerStyle.Render(text)
+		case strings.HasPrefix(text, "+"):
+			text = addedStyle.Render(text)
+		case strings.HasPrefix(text, "-"):
+			text = removedStyle.Render(text)
+		case strings.HasPrefix(text, "@@"):
+			text = hunkStyle.Render(text)
+		}
+
+		if strings.HasSuffix(line, "\n") {
+			text += "\n"
+		}
+		lines[i] = text
+	}
+
+	return strings.Join(lines, "")
+}
+
+// notesCmd renders the release notes of each image's tag range in the background,
+// an image deployed to several namespaces at the same tag only has its notes rendered once.
+func (m Model) notesCmd() tea.Cmd {
+	if m.renderNotes == nil {
+		return nil
+	}
+
+	type tagRange struct{ image, tag string }
+	ranges := []tagRange{}
+	seen := map[tagRange]bool{}
+	for _, target := range m.targets() {
+		r := tagRange{image: target.ImageName(), tag: target.Tag}
+		if r.tag == "" || r.tag == m.imageVersion || seen[r] {
+			continue
+		}
+
+		seen[r] = true
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	renderNotes, version := m.renderNotes, m.imageVersion
+	return func() tea.Msg {
+		notes := strings.Builder{}
+		for _, r := range ranges {
+			text, err := renderNotes(r.image, r.tag, version)
+			if err != nil {
+				fmt.Fprintf(&notes, "no release notes for %s %s → %s: %s\n", r.image, r.tag, version, err)
+				continue
+			}
+
+			notes.WriteString(text)
+		}
+
+		return notesMsg{version: version, notes: notes.String()}
+	}
+}
+
+// imageName is the image the tags are listed for, its newName when it has one.
+func (m Model) imageName(name string) string {
+	for _, ns := range m.namespaces {
+		kustFile := m.kustFiles[ns]
+		if kustFile == nil {
+			continue
+		}
+
+		for _, image := range kustFile.Images {
+			if image.Name == name && image.NewName != "" {
+				return image.NewName
+			}
+		}
+	}
+
+	return name
+}
+
+// setSuggestions offers the tags newer than the current version, newest first.
+func (m *Model) setSuggestions(msg tagsMsg) {
+	m.fetchingTags = false
+	if msg.err != nil {
+		m.input.SetStatus(fmt.Sprintf("no suggestions: %s", msg.err))
+		return
+	}
+
+	m.knownTags = msg.tags
+
+	targets := m.targets()
+	current := ""
+	if len(targets) > 0 {
+		current = targets[0].Tag
+	}
+
+	// keep the v when the current tag has one
+	prefix := ""
+	if strings.HasPrefix(current, "v") {
+		prefix = "v"
+	}
+
+	currentVersion, err := semver.NewVersion(strings.TrimPrefix(current, "v"))
+	suggestions := []suggest.Suggestion{}
+	for _, tag := range msg.tags {
+		v, vErr := semver.NewVersion(tag.Version)
+		if vErr != nil || (err == nil && !v.GreaterThan(currentVersion)) {
+			continue
+		}
+
+		suggestions = append(suggestions, suggest.Suggestion{
+			Value:       prefix + tag.Version,
+			Description: fmt.Sprintf("%s (%s)", tag.Subject, tag.Date.Format("2006-01-02")),
+		})
+	}
+
+	m.input.SetSuggestions(suggestions)
+	m.input.SetStatus("")
+	if len(suggestions) == 0 {
+		m.input.SetStatus(fmt.Sprintf("no tags newer than %s", current))
+	}
+}
+
+// validateVersion rejects anything that isn't an image tag, and semantic versions without a git tag
+// when the tags are known. Other tags, i.e main-3eb443b, can't be checked so are allowed.
+func (m Model) validateVersion(version string) error {
+	if !imageTagPattern.MatchString(version) {
+		return fmt.Errorf("%q is not a valid image tag", version)
+	}
+
+	want, err := semver.NewVersion(strings.TrimPrefix(version, "v"))
+	if m.knownTags == nil || err != nil {
+		return nil
+	}
+
+	for _, tag := range m.knownTags {
+		if v, err := semver.NewVersion(tag.Version); err == nil && v.Equal(want) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("there's no git tag for %s", version)
+}
+
+// targets are the selected images within each selected namespace using them.
+func (m Model) targets() []Target {
+	targets := []Target{}
+	for _, ns := range m.namespaces {
+		kustFile := m.kustFiles[ns]
+		if kustFile == nil {
+			continue
+		}
+
+		for _, name := range m.images {
+			for _, image := range kustFile.Images {
+				if image.Name == name {
+					targets = append(targets, Target{Environment: m.environment, Namespace: ns, Image: name, NewName: image.NewName, Tag: image.NewTag})
+					break
+				}
+			}
+		}
+	}
+
+	return targets
+}
+
 func (m Model) View() string {
 	if m.err != nil {
 		return fmt.Sprintf("Error: %s", m.err.Error())
 	}
 
-	if m.step == VersionPage {
+	switch m.step {
+	case VersionPage:
 		return m.input.View()
+	case ConfirmPage:
+		help := "\n" + helpStyle.Render("↑/↓/pgup/pgdn: scroll • y/enter: write • b/esc: back • q: abort") + "\n"
+
+		// the terminal's size isn't known until the first WindowSizeMsg
+		if m.confirmView.Height == 0 {
+			return m.confirmContent() + help
+		}
+
+		return m.confirmView.View() + help
+	case DonePage:
+		if m.aborted {
+			return "Aborted, nothing was written.\n"
+		}
+
+		view := strings.Builder{}
+		for _, result := range m.results {
+			if result.Err != nil {
+				fmt.Fprintf(&view, "✗ %s: %s: %s\n", result.Target.Path(), result.Target.Image, result.Err)
+				continue
+			}
+
+			fmt.Fprintf(&view, "✓ %s: %s %s → %s\n", result.Target.Path(), result.Target.Image, result.Target.Tag, m.imageVersion)
+		}
+
+		return view.String()
 	}
+
 	return m.list.View()
 }
@@ -1,13 +1,16 @@
 package wizard
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/pmezard/go-difflib/difflib"
 	"gopkg.in/yaml.v2"
 	"sigs.k8s.io/kustomize/api/types"
 )
@@ -88,6 +91,9 @@ func updateImageInMapSlice(m *yaml.MapSlice, image, version string) bool {
 
 }
 
+// ErrImageNotFound is returned when a kustomization has no newTag for the image.
+var ErrImageNotFound = errors.New("image not found")
+
 func updateImageVersion(filepath, image, version string) error {
 	m, err := readMapSliceFromFile(filepath)
 	if err != nil {
@@ -96,7 +102,7 @@ func updateImageVersion(filepath, image, version string) error {
 
 	didUpdate := updateImageInMapSlice(&m, image, version)
 	if !didUpdate {
-		return fmt.Errorf("failed to update image %s to version %s", image, version)
+		return fmt.Errorf("failed to update image %s to version %s: %w", image, version, ErrImageNotFound)
 	}
 
 	err = writeMapSliceToFile(filepath, m)
@@ -107,14 +113,56 @@ func updateImageVersion(filepath, image, version string) error {
 	return nil
 }
 
+// PreviewImageVersion returns the kustomization.yaml before and after updating the image, without writing it.
+func PreviewImageVersion(base, env, ns, image, version string) (before string, after string, err error) {
+	filepath := path.Join(base, KustomizationPath(env, ns))
+
+	original, err := os.ReadFile(filepath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read file %s: %w", filepath, err)
+	}
+
+	m, err := readMapSliceFromFile(filepath)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !updateImageInMapSlice(&m, image, version) {
+		return "", "", fmt.Errorf("failed to update image %s to version %s: %w", image, version, ErrImageNotFound)
+	}
+
+	updated, err := yaml.Marshal(m)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal file %s: %w", filepath, err)
+	}
+
+	return string(original), string(updated), nil
+}
+
+// UnifiedDiff is the diff between two versions of a file, like git diff.
+func UnifiedDiff(filepath, before, after string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: "a/" + filepath,
+		ToFile:   "b/" + filepath,
+		Context:  3,
+	})
+}
+
 func UpdateImageVersion(base, env, ns, image, version string) error {
-	filepath := path.Join(base, fmt.Sprintf("environments/engine-%s/baseline/%s/kustomization.yaml", env, ns))
+	filepath := path.Join(base, KustomizationPath(env, ns))
 
 	return updateImageVersion(filepath, image, version)
 }
 
+// KustomizationPath is the kustomization.yaml of a namespace, relative to the root of the repo.
+func KustomizationPath(env, ns string) string {
+	return fmt.Sprintf("environments/engine-%s/baseline/%s/kustomization.yaml", env, ns)
+}
+
 func OpenKustomization(base, env, ns string) (*types.Kustomization, error) {
-	filepath := path.Join(base, fmt.Sprintf("environments/engine-%s/baseline/%s/kustomization.yaml", env, ns))
+	filepath := path.Join(base, KustomizationPath(env, ns))
 
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -150,3 +198,157 @@ func GetNamespaces(basepath, env string) ([]string, error) {
 
 	return namespaces, nil
 }
+
+// Target is a kustomization.yaml setting the image being updated.
+type Target struct {
+	Environment string
+	Namespace   string
+	Image       string
+	// NewName is the image's newName, empty when it isn't replaced.
+	NewName string
+	// Tag is the tag currently deployed.
+	Tag string
+}
+
+// ImageName is the image deployed, its newName when it has one.
+func (t Target) ImageName() string {
+	if t.NewName != "" {
+		return t.NewName
+	}
+
+	return t.Image
+}
+
+// Path is the target's kustomization.yaml, relative to the root of the repo.
+func (t Target) Path() string {
+	return KustomizationPath(t.Environment, t.Namespace)
+}
+
+// FindTargets returns every kustomization setting image, within the environments and namespaces
+// matching the glob patterns, i.e prod and wb-*. Namespaces without the image are skipped.
+func FindTargets(basepath string, environments []string, envPattern, nsPattern, image string) ([]Target, error) {
+	targets := []Target{}
+	for _, env := range environments {
+		matched, err := path.Match(envPattern, env)
+		if err != nil {
+			return nil, fmt.Errorf("invalid environment pattern %s: %w", envPattern, err)
+		}
+
+		if !matched {
+			continue
+		}
+
+		directories, err := filepath.Glob(path.Join(basepath, fmt.Sprintf("environments/engine-%s/baseline/%s", env, nsPattern)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %s: %w", nsPattern, err)
+		}
+		sort.Strings(directories)
+
+		for _, dir := range directories {
+			ns := filepath.Base(dir)
+			if !exists(path.Join(basepath, KustomizationPath(env, ns))) {
+				continue
+			}
+
+			k, err := OpenKustomization(basepath, env, ns)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, i := range k.Images {
+				if i.Name == image {
+					targets = append(targets, Target{Environment: env, Namespace: ns, Image: image, NewName: i.NewName, Tag: i.NewTag})
+					break
+				}
+			}
+		}
+	}
+
+	return targets, nil
+}
+
+// Promotion is an image whose tag in the target environment differs from the source environment.
+type Promotion struct {
+	// Target is the kustomization being updated, Tag is the tag it currently has.
+	Target
+	// NewTag is the tag deployed in the source environment.
+	NewTag string
+}
+
+// PlanPromotion compares the namespaces of two environments, returning the images to update in the
+// to environment so they match the from environment. Namespaces and images are filtered by the glob
+// patterns, an empty image pattern matches every image. Images or namespaces only found in the from
+// environment aren't added, nor are images without a newTag in the to environment as there's no tag to update.
+func PlanPromotion(basepath, from, to, nsPattern, imagePattern string) ([]Promotion, error) {
+	directories, err := filepath.Glob(path.Join(basepath, fmt.Sprintf("environments/engine-%s/baseline/%s", to, nsPattern)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace pattern %s: %w", nsPattern, err)
+	}
+	sort.Strings(directories)
+
+	promotions := []Promotion{}
+	for _, dir := range directories {
+		ns := filepath.Base(dir)
+		if !exists(path.Join(basepath, KustomizationPath(to, ns))) || !exists(path.Join(basepath, KustomizationPath(from, ns))) {
+			continue
+		}
+
+		source, err := OpenKustomization(basepath, from, ns)
+		if err != nil {
+			return nil, err
+		}
+
+		dest, err := OpenKustomization(basepath, to, ns)
+		if err != nil {
+			return nil, err
+		}
+
+		sourceTags := map[string]string{}
+		for _, image := range source.Images {
+			sourceTags[image.Name] = image.NewTag
+		}
+
+		for _, image := range dest.Images {
+			matched := imagePattern == ""
+			if !matched {
+				if matched, err = path.Match(imagePattern, image.Name); err != nil {
+					return nil, fmt.Errorf("invalid image pattern %s: %w", imagePattern, err)
+				}
+			}
+
+			newTag, ok := sourceTags[image.Name]
+			if !matched || !ok || newTag == "" || image.NewTag == "" || newTag == image.NewTag {
+				continue
+			}
+
+			promotions = append(promotions, Promotion{
+				Target: Target{Environment: to, Namespace: ns, Image: image.Name, NewName: image.NewName, Tag: image.NewTag},
+				NewTag: newTag,
+			})
+		}
+	}
+
+	return promotions, nil
+}
+
+func exists(filepath string) bool {
+	_, err := os.Stat(filepath)
+	return err == nil
+}
+
+// Result is the outcome of updating a single target.
+type Result struct {
+	Target Target
+	Err    error
+}
+
+// UpdateTargets sets the image of every target to version, carrying on past failures.
+func UpdateTargets(basepath string, targets []Target, version string) []Result {
+	results := make([]Result, 0, len(targets))
+	for _, target := range targets {
+		err := UpdateImageVersion(basepath, target.Environment, target.Namespace, target.Image, version)
+		results = append(results, Result{Target: target, Err: err})
+	}
+
+	return results
+}
@@ -1,8 +1,15 @@
 package wizard
 
 import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
 	"testing"
 
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/stretchr/testify/require"
 )
 
@@ -16,3 +23,366 @@ func TestReadWrite(t *testing.T) {
 	field := findImageVersionInMapSlice(&m, "some-image", "9.9.9")
 	require.Equal(t, "9.9.9", *field)
 }
+
+// k8sEngine lays out a kustomization.yaml for each environment and namespace, setting the image tags.
+func k8sEngine(t *testing.T, overlays map[string]string) string {
+	base := t.TempDir()
+	for overlay, images := range overlays {
+		env, ns, _ := strings.Cut(overlay, "/")
+		filepath := path.Join(base, KustomizationPath(env, ns))
+		require.NoError(t, os.MkdirAll(path.Dir(filepath), 0755))
+		require.NoError(t, os.WriteFile(filepath, []byte("kind: Kustomization\nnamespace: "+ns+"\nimages:\n"+images), 0644))
+	}
+
+	return base
+}
+
+var (
+	enter = tea.KeyMsg{Type: tea.KeyEnter}
+	down  = tea.KeyMsg{Type: tea.KeyDown}
+	space = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+)
+
+func keys(s string) tea.Msg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// driver feeds messages to the wizard like bubbletea does, keeping the model and the last command returned.
+type driver struct {
+	m   tea.Model
+	cmd tea.Cmd
+}
+
+func drive(m Model) *driver {
+	return &driver{m: m}
+}
+
+func (d *driver) send(msgs ...tea.Msg) {
+	for _, msg := range msgs {
+		d.m, d.cmd = d.m.Update(msg)
+	}
+}
+
+// run runs the last command, including every command of a batch, and sends the messages back to the model.
+func (d *driver) run() {
+	var run func(cmd tea.Cmd)
+	run = func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for _, cmd := range batch {
+				run(cmd)
+			}
+			return
+		}
+
+		d.send(msg)
+	}
+
+	run(d.cmd)
+}
+
+func (d *driver) model() Model {
+	return d.m.(Model)
+}
+
+func TestFindTargets(t *testing.T) {
+	base := k8sEngine(t, map[string]string{
+		"prod/wb-lfqa":    "- name: some-image\n  newTag: 1.2.3\n",
+		"prod/wb-other":   "- name: some-image\n  newTag: 1.2.0\n",
+		"prod/ops":        "- name: some-image\n  newTag: 1.0.0\n",
+		"prod/wb-nothing": "- name: fake-image\n  newTag: 2.3.4\n",
+		"stage/wb-lfqa":   "- name: some-image\n  newTag: 1.3.0\n",
+	})
+	environments := []string{"dev", "stage", "prod"}
+
+	targets, err := FindTargets(base, environments, "prod", "wb-*", "some-image")
+	require.NoError(t, err)
+	require.Equal(t, []Target{
+		{Environment: "prod", Namespace: "wb-lfqa", Image: "some-image", Tag: "1.2.3"},
+		{Environment: "prod", Namespace: "wb-other", Image: "some-image", Tag: "1.2.0"},
+	}, targets)
+	require.Equal(t, "environments/engine-prod/baseline/wb-lfqa/kustomization.yaml", targets[0].Path())
+
+	targets, err = FindTargets(base, environments, "*", "*", "some-image")
+	require.NoError(t, err)
+	require.Len(t, targets, 4)
+	require.Equal(t, "stage", targets[0].Environment, "ordered by the configured environments")
+
+	targets, err = FindTargets(base, environments, "prod", "wb-lfqa", "missing-image")
+	require.NoError(t, err)
+	require.Empty(t, targets)
+}
+
+func TestPreviewImageVersion(t *testing.T) {
+	base := k8sEngine(t, map[string]string{
+		"prod/wb-lfqa": "- name: some-image\n  newTag: 1.2.3\n",
+	})
+
+	before, after, err := PreviewImageVersion(base, "prod", "wb-lfqa", "some-image", "1.4.0")
+	require.NoError(t, err)
+
+	diff, err := UnifiedDiff(KustomizationPath("prod", "wb-lfqa"), before, after)
+	require.NoError(t, err)
+	require.Contains(t, diff, "--- a/environments/engine-prod/baseline/wb-lfqa/kustomization.yaml")
+	require.Contains(t, diff, "-  newTag: 1.2.3\n+  newTag: 1.4.0\n")
+
+	// the file is left alone
+	k, err := OpenKustomization(base, "prod", "wb-lfqa")
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3", k.Images[0].NewTag)
+
+	_, _, err = PreviewImageVersion(base, "prod", "wb-lfqa", "missing-image", "1.4.0")
+	require.ErrorIs(t, err, ErrImageNotFound)
+}
+
+func TestPlanPromotion(t *testing.T) {
+	base := k8sEngine(t, map[string]string{
+		"stage/wb-lfqa":  "- name: adarga/some-service\n  newTag: 1.3.0\n- name: adarga/other-service\n  newTag: 2.0.0\n- name: adarga/stage-only\n  newTag: 0.1.0\n- name: bitnami/redis\n  newTag: \"7.2\"\n",
+		"prod/wb-lfqa":   "- name: adarga/some-service\n  newName: ghcr.io/adarga/some-service\n  newTag: 1.2.3\n- name: adarga/other-service\n  newTag: 2.0.0\n- name: bitnami/redis\n  newTag: \"7.0\"\n",
+		"stage/wb-other": "- name: adarga/some-service\n  newTag: 1.3.0\n- name: adarga/pinned\n  newTag: 1.0.0\n",
+		// without a newTag there's nothing to update, it's left out rather than failing the promotion
+		"prod/wb-other": "- name: adarga/some-service\n  newTag: 1.1.0\n- name: adarga/pinned\n  newName: ghcr.io/adarga/pinned\n",
+		"stage/wb-new":  "- name: adarga/some-service\n  newTag: 1.3.0\n",
+	})
+
+	plan, err := PlanPromotion(base, "stage", "prod", "*", "")
+	require.NoError(t, err)
+	require.Equal(t, []Promotion{
+		{Target: Target{Environment: "prod", Namespace: "wb-lfqa", Image: "adarga/some-service", NewName: "ghcr.io/adarga/some-service", Tag: "1.2.3"}, NewTag: "1.3.0"},
+		{Target: Target{Environment: "prod", Namespace: "wb-lfqa", Image: "bitnami/redis", Tag: "7.0"}, NewTag: "7.2"},
+		{Target: Target{Environment: "prod", Namespace: "wb-other", Image: "adarga/some-service", Tag: "1.1.0"}, NewTag: "1.3.0"},
+	}, plan)
+
+	plan, err = PlanPromotion(base, "stage", "prod", "wb-lfqa", "adarga/*")
+	require.NoError(t, err)
+	require.Len(t, plan, 1)
+	require.Equal(t, "adarga/some-service", plan[0].Image)
+	require.Equal(t, "ghcr.io/adarga/some-service", plan[0].ImageName(), "the notes are found through the newName")
+
+	plan, err = PlanPromotion(base, "stage", "dev", "*", "")
+	require.NoError(t, err)
+	require.Empty(t, plan)
+}
+
+func TestModelMultiSelect(t *testing.T) {
+	base := k8sEngine(t, map[string]string{
+		"prod/wb-a": "- name: some-image\n  newTag: 1.2.3\n- name: other-image\n  newTag: 2.0.0\n",
+		"prod/wb-b": "- name: some-image\n  newTag: 1.2.3\n",
+		// without a newTag the update fails, the rest still go ahead
+		"prod/wb-c": "- name: some-image\n  newName: ghcr.io/some-image\n",
+	})
+
+	d := drive(NewModel(config.Default(), base, nil, nil))
+
+	// prod, every namespace, then only some-image
+	d.send(down, down, enter, keys("a"), enter, space, enter)
+	require.Equal(t, VersionPage, d.model().step)
+	require.Equal(t, []string{"wb-a", "wb-b", "wb-c"}, d.model().namespaces)
+	require.Equal(t, []string{"some-image"}, d.model().images)
+
+	d.send(keys("1.4.0"), enter, keys("y"))
+	require.Equal(t, DonePage, d.model().step)
+
+	results := d.model().results
+	require.Len(t, results, 3)
+	require.NoError(t, results[0].Err)
+	require.NoError(t, results[1].Err)
+	require.ErrorIs(t, results[2].Err, ErrImageNotFound)
+
+	for _, ns := range []string{"wb-a", "wb-b"} {
+		k, err := OpenKustomization(base, "prod", ns)
+		require.NoError(t, err)
+		require.Equal(t, "1.4.0", k.Images[0].NewTag, ns)
+	}
+
+	view := d.m.View()
+	require.Contains(t, view, "✓ environments/engine-prod/baseline/wb-a/kustomization.yaml: some-image 1.2.3 → 1.4.0")
+	require.Contains(t, view, "✗ environments/engine-prod/baseline/wb-c/kustomization.yaml: some-image:")
+}
+
+func TestModelSelectAllFiltered(t *testing.T) {
+	base := k8sEngine(t, map[string]string{
+		"prod/wb-lfqa-a": "- name: some-image\n  newTag: 1.2.3\n",
+		"prod/wb-lfqa-b": "- name: some-image\n  newTag: 1.2.3\n",
+		"prod/wb-other":  "- name: some-image\n  newTag: 1.2.3\n",
+	})
+
+	d := drive(NewModel(config.Default(), base, nil, nil))
+	d.send(down, down, enter, keys("/"), keys("lfqa"))
+	d.run()
+	require.Len(t, d.model().list.VisibleItems(), 2)
+	d.send(tea.KeyMsg{Type: tea.KeyTab})
+
+	// only the namespaces left by the filter are selected
+	d.send(keys("a"), enter)
+	require.Equal(t, ImagePage, d.model().step)
+	require.Equal(t, []string{"wb-lfqa-a", "wb-lfqa-b"}, d.model().namespaces)
+}
+
+func TestModelSuggestsTags(t *testing.T) {
+	base := k8sEngine(t, map[string]string{
+		"prod/wb-a": "- name: some-image\n  newName: ghcr.io/adarga/some-image\n  newTag: 1.2.3\n",
+	})
+
+	listed := ""
+	listTags := func(image string) ([]git.TagInfo, error) {
+		listed = image
+		return []git.TagInfo{
+			{Name: "v1.4.0", Version: "1.4.0", Subject: "feat: rating"},
+			{Name: "v1.3.0", Version: "1.3.0", Subject: "fix: nil rating"},
+			{Name: "v1.2.3", Version: "1.2.3"},
+			{Name: "v1.0.0", Version: "1.0.0"},
+		}, nil
+	}
+
+	d := drive(NewModel(config.Default(), base, listTags, nil))
+	clear := tea.KeyMsg{Type: tea.KeyCtrlU}
+
+	d.send(down, down, enter, enter, enter)
+	require.Equal(t, VersionPage, d.model().step)
+	require.NotNil(t, d.cmd, "the tags are listed in the background")
+	fetch := d.cmd
+
+	// nothing can be confirmed until the tags arrive, they're needed to check the version
+	d.send(keys("1.9.9"), enter)
+	require.Equal(t, VersionPage, d.model().step)
+	require.Contains(t, d.m.View(), "still fetching tags")
+
+	d.send(clear, fetch())
+	require.Equal(t, "ghcr.io/adarga/some-image", listed)
+
+	view := d.m.View()
+	require.Contains(t, view, "1.4.0")
+	require.Contains(t, view, "feat: rating")
+	require.NotContains(t, view, "1.0.0", "only newer tags are suggested")
+
+	d.send(keys("1.9.9"), enter)
+	require.Equal(t, VersionPage, d.model().step)
+	require.Contains(t, d.m.View(), "there's no git tag for 1.9.9")
+
+	d.send(clear, keys("not a tag"), enter)
+	require.Equal(t, VersionPage, d.model().step)
+	require.Contains(t, d.m.View(), "is not a valid image tag")
+
+	// tags that aren't versions can't be checked, so are allowed
+	require.NoError(t, d.model().validateVersion("main-3eb443b"))
+
+	d.send(clear, down, enter, enter)
+	require.Equal(t, DonePage, d.model().step)
+
+	k, err := OpenKustomization(base, "prod", "wb-a")
+	require.NoError(t, err)
+	require.Equal(t, "1.4.0", k.Images[0].NewTag)
+}
+
+func TestModelConfirm(t *testing.T) {
+	base := k8sEngine(t, map[string]string{
+		"prod/wb-a": "- name: some-image\n  newName: ghcr.io/adarga/some-image\n  newTag: 1.2.3\n",
+		"prod/wb-b": "- name: some-image\n  newName: ghcr.io/adarga/some-image\n  newTag: 1.2.3\n",
+	})
+
+	rendered := []string{}
+	renderNotes := func(image, tag1, tag2 string) (string, error) {
+		rendered = append(rendered, fmt.Sprintf("%s %s..%s", image, tag1, tag2))
+		return "- ABC-123 fix the rating\n", nil
+	}
+
+	d := drive(NewModel(config.Default(), base, nil, renderNotes))
+	unchanged := func() {
+		t.Helper()
+		for _, ns := range []string{"wb-a", "wb-b"} {
+			k, err := OpenKustomization(base, "prod", ns)
+			require.NoError(t, err)
+			require.Equal(t, "1.2.3", k.Images[0].NewTag, ns)
+		}
+	}
+
+	d.send(down, down, enter, keys("a"), enter, enter, keys("1.4.0"), enter)
+	require.Equal(t, ConfirmPage, d.model().step)
+	require.NotNil(t, d.cmd, "the notes are rendered in the background")
+	unchanged()
+
+	view := d.m.View()
+	require.Contains(t, view, "+++ b/environments/engine-prod/baseline/wb-a/kustomization.yaml")
+	require.Contains(t, view, "+++ b/environments/engine-prod/baseline/wb-b/kustomization.yaml")
+	require.Contains(t, view, "+  newTag: 1.4.0")
+	require.Contains(t, view, "generating release notes…")
+
+	d.send(d.cmd())
+	require.Equal(t, []string{"ghcr.io/adarga/some-image 1.2.3..1.4.0"}, rendered, "the notes are only rendered once per tag range")
+	require.Contains(t, d.m.View(), "ABC-123 fix the rating")
+
+	// back to the version page, the notes for the old version are dropped
+	d.send(keys("b"))
+	require.Equal(t, VersionPage, d.model().step)
+	d.send(keys("1"), enter)
+	require.Equal(t, ConfirmPage, d.model().step)
+	stale := notesMsg{version: "1.4.0", notes: "stale"}
+	d.send(stale)
+	require.NotContains(t, d.m.View(), "stale")
+	unchanged()
+
+	d.send(keys("q"))
+	require.Equal(t, DonePage, d.model().step)
+	require.Contains(t, d.m.View(), "nothing was written")
+	unchanged()
+}
+
+func TestModelQuitsOnVersionPage(t *testing.T) {
+	base := k8sEngine(t, map[string]string{
+		"prod/wb-a": "- name: some-image\n  newTag: 1.2.3\n",
+	})
+
+	for _, key := range []tea.KeyMsg{{Type: tea.KeyCtrlC}, {Type: tea.KeyEsc}} {
+		d := drive(NewModel(config.Default(), base, nil, nil))
+		d.send(down, down, enter, enter, enter, keys("1.4"))
+		require.Equal(t, VersionPage, d.model().step)
+
+		// quitting doesn't need a valid tag
+		d.send(key)
+		require.NotNil(t, d.cmd, key.String())
+		require.IsType(t, tea.QuitMsg{}, d.cmd(), key.String())
+	}
+
+	k, err := OpenKustomization(base, "prod", "wb-a")
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3", k.Images[0].NewTag)
+}
+
+func TestModelConfirmScrolls(t *testing.T) {
+	base := k8sEngine(t, map[string]string{
+		"prod/wb-a": "- name: some-image\n  newTag: 1.2.3\n",
+	})
+
+	notes := strings.Builder{}
+	for i := 1; i <= 50; i++ {
+		fmt.Fprintf(&notes, "- ABC-%d fix the rating\n", i)
+	}
+	renderNotes := func(image, tag1, tag2 string) (string, error) {
+		return notes.String(), nil
+	}
+
+	d := drive(NewModel(config.Default(), base, nil, renderNotes))
+	d.send(tea.WindowSizeMsg{Width: 80, Height: 20})
+	d.send(down, down, enter, enter, enter, keys("1.4.0"), enter)
+	d.send(d.cmd())
+	require.Equal(t, ConfirmPage, d.model().step)
+
+	// the top of the diff is shown first, the help line is always shown
+	view := d.m.View()
+	require.Contains(t, view, "+++ b/environments/engine-prod/baseline/wb-a/kustomization.yaml")
+	require.NotContains(t, view, "ABC-50 ")
+	require.Contains(t, view, "y/enter: write")
+	require.LessOrEqual(t, strings.Count(view, "\n"), 20)
+
+	d.send(tea.KeyMsg{Type: tea.KeyPgDown}, tea.KeyMsg{Type: tea.KeyPgDown}, tea.KeyMsg{Type: tea.KeyPgDown})
+	view = d.m.View()
+	require.NotContains(t, view, "+++ b/environments/engine-prod/baseline/wb-a/kustomization.yaml")
+	require.Contains(t, view, "ABC-50 ")
+	require.Contains(t, view, "y/enter: write")
+	require.Equal(t, ConfirmPage, d.model().step)
+}
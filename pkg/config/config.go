@@ -0,0 +1,514 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FileName is the name of the project-level config file looked up in the repo root.
+const FileName = ".release-notes.yaml"
+
+// The supported issue trackers.
+const (
+	TrackerJira   = "jira"
+	TrackerGitHub = "github"
+	TrackerLinear = "linear"
+)
+
+// The ways notes are grouped within a repo.
+const (
+	// GroupByIssue lists the issues referenced by the commits.
+	GroupByIssue = "issue"
+	// GroupByType splits the commits by their conventional commit type, i.e Features and Fixes.
+	GroupByType = "type"
+)
+
+// The strategies used to find the commit an image tag was built from.
+const (
+	// TagStrategySemver matches the image tag against semver git tags, i.e 1.2.3 to service/v1.2.3
+	TagStrategySemver = "semver"
+	// TagStrategyCalver matches the image tag against calendar versioned git tags, i.e 2023.10.01
+	TagStrategyCalver = "calver"
+	// TagStrategySHA treats the image tag as a commit SHA.
+	TagStrategySHA = "sha"
+	// TagStrategyRegex takes the git tag or commit SHA from the first capture group of a pattern.
+	TagStrategyRegex = "regex"
+)
+
+// Config holds everything that used to be hardcoded for a single org.
+type Config struct {
+	// Tracker is the issue tracker used for repos without their own entry in Repos.
+	Tracker   string          `yaml:"tracker"`
+	GitHub    GitHub          `yaml:"github"`
+	Jira      Jira            `yaml:"jira"`
+	Linear    Linear          `yaml:"linear"`
+	K8sEngine K8sEngine       `yaml:"k8sEngine"`
+	Images    Images          `yaml:"images"`
+	Cache     Cache           `yaml:"cache"`
+	Templates Templates       `yaml:"templates"`
+	Untracked Untracked       `yaml:"untracked"`
+	Tags      Tags            `yaml:"tags"`
+	Repos     map[string]Repo `yaml:"repos"`
+}
+
+type GitHub struct {
+	// Org is the GitHub organisation that owns the service repos and k8s-engine.
+	Org string `yaml:"org"`
+	// CloneURL is the prefix repos are cloned from, defaults to git@github.com:<org>/
+	CloneURL string `yaml:"cloneURL"`
+	// TicketPatterns find GitHub Issues references in commits, i.e #123 or org/repo#123
+	TicketPatterns []string `yaml:"ticketPatterns"`
+}
+
+type Jira struct {
+	Host string `yaml:"host"`
+	// TicketPatterns are the regexes used to find tickets in commits, one per project.
+	TicketPatterns []string `yaml:"ticketPatterns"`
+	// BatchSize is the number of tickets fetched per JQL search.
+	BatchSize int `yaml:"batchSize"`
+}
+
+type Linear struct {
+	// Workspace is the url key of the Linear workspace, used for links.
+	Workspace      string   `yaml:"workspace"`
+	TicketPatterns []string `yaml:"ticketPatterns"`
+}
+
+type Cache struct {
+	// Enabled keeps mirrors of cloned repos on disk between runs.
+	Enabled bool `yaml:"enabled"`
+	// Dir defaults to ~/.cache/release-notes/repos
+	Dir string `yaml:"dir"`
+}
+
+// Templates override the embedded notes and env templates.
+// A file set for a template takes precedence over the same template in Dir.
+type Templates struct {
+	// Dir holds notes.template and/or env.template
+	Dir   string `yaml:"dir"`
+	Notes string `yaml:"notes"`
+	Env   string `yaml:"env"`
+	// GroupBy is either issue or type, type renders grouped.template rather than notes.template
+	GroupBy string `yaml:"groupBy"`
+}
+
+// Untracked controls the commits listed because they don't reference an issue.
+type Untracked struct {
+	// IgnorePatterns are matched against the commit subject, i.e ^chore\(deps\)
+	IgnorePatterns []string `yaml:"ignorePatterns"`
+	// IgnoreAuthors are author names or emails, i.e dependabot[bot]
+	IgnoreAuthors []string `yaml:"ignoreAuthors"`
+}
+
+// Repo holds per repo settings, keyed by repo name.
+type Repo struct {
+	Tracker string `yaml:"tracker"`
+	// Priority orders repos in the notes, lowest first. Repos without one follow alphabetically.
+	Priority int `yaml:"priority"`
+	// Tags replaces the top level tags when a strategy is set.
+	Tags Tags `yaml:"tags"`
+}
+
+// Tags controls how an image tag is resolved to a commit.
+// When the strategy fails, a commit SHA found in the image tag (i.e main-3eb443b) is used instead.
+type Tags struct {
+	// Strategy is one of semver, calver, sha or regex.
+	Strategy string `yaml:"strategy"`
+	// Prefixes are stripped from git and image tags by semver and calver, i.e some-service/ or deploy-
+	// A leading v is always stripped.
+	Prefixes []string `yaml:"prefixes"`
+	// Pattern is used by regex, i.e ^main-([0-9a-f]{7,40})$
+	Pattern string `yaml:"pattern"`
+}
+
+type K8sEngine struct {
+	// Repo is the name of the repo holding the kustomizations, PRs are opened against it.
+	Repo         string   `yaml:"repo"`
+	Environments []string `yaml:"environments"`
+	// Render builds the overlays with kustomize rather than reading their images: stanza,
+	// so images set in bases, components, patches or replacements are found too.
+	Render bool `yaml:"render"`
+}
+
+type Images struct {
+	// RepoPrefix is the path segment in an image name that precedes the repo name,
+	// i.e. adarga/ in 1234.dkr.ecr.eu-west-2.amazonaws.com/adarga/some-service
+	RepoPrefix string `yaml:"repoPrefix"`
+	// NewCommits is the number of commits listed for a newly deployed image.
+	NewCommits int `yaml:"newCommits"`
+}
+
+// ValidationError names the config key that failed validation.
+type ValidationError struct {
+	Key    string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config key %q: %s", e.Key, e.Reason)
+}
+
+// Default returns the config used when no file or override is given.
+func Default() *Config {
+	return &Config{
+		Tracker: TrackerJira,
+		GitHub: GitHub{
+			Org: "Adarga-Ltd",
+			// the (#123) squash merge suffix is a PR, not an issue
+			TicketPatterns: []string{`(?:^|[^(\w/.-])((?:[\w.-]+/[\w.-]+)?#\d+)`},
+		},
+		Jira: Jira{
+			Host:           "https://adarga.atlassian.net",
+			TicketPatterns: []string{`APP-\d+`},
+			BatchSize:      50,
+		},
+		K8sEngine: K8sEngine{
+			Repo:         "k8s-engine",
+			Environments: []string{"dev", "stage", "prod"},
+		},
+		Images: Images{
+			RepoPrefix: "adarga/",
+			NewCommits: 20,
+		},
+		Cache: Cache{
+			Enabled: true,
+		},
+		Templates: Templates{
+			GroupBy: GroupByIssue,
+		},
+		Tags: Tags{
+			Strategy: TagStrategySemver,
+			Prefixes: []string{"deploy-"},
+		},
+	}
+}
+
+// Load reads the config at path on top of the defaults.
+// If path is empty the config is discovered, see Discover.
+// Environment variable overrides are applied after the file.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = Discover()
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+		}
+
+		if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+
+	cfg.applyEnv()
+
+	return cfg, nil
+}
+
+// Discover returns the first config file found in the root of the
+// git repo containing the working directory, then $XDG_CONFIG_HOME/release-notes/config.yaml.
+// Returns an empty string when nothing is found.
+func Discover() string {
+	candidates := []string{}
+
+	if wd, err := os.Getwd(); err == nil {
+		if root := findRepoRoot(wd); root != "" {
+			candidates = append(candidates, filepath.Join(root, FileName))
+		}
+	}
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "release-notes", "config.yaml"))
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// walks up from dir until it finds a directory containing .git
+func findRepoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// envOverrides maps environment variables to the field they override.
+func (c *Config) envOverrides() map[string]*string {
+	return map[string]*string{
+		"RELEASE_NOTES_TRACKER":           &c.Tracker,
+		"RELEASE_NOTES_GITHUB_ORG":        &c.GitHub.Org,
+		"RELEASE_NOTES_GITHUB_CLONE_URL":  &c.GitHub.CloneURL,
+		"RELEASE_NOTES_JIRA_HOST":         &c.Jira.Host,
+		"RELEASE_NOTES_LINEAR_WORKSPACE":  &c.Linear.Workspace,
+		"RELEASE_NOTES_K8S_ENGINE_REPO":   &c.K8sEngine.Repo,
+		"RELEASE_NOTES_IMAGE_REPO_PREFIX": &c.Images.RepoPrefix,
+		"RELEASE_NOTES_CACHE_DIR":         &c.Cache.Dir,
+		"RELEASE_NOTES_TEMPLATES_DIR":     &c.Templates.Dir,
+		"RELEASE_NOTES_GROUP_BY":          &c.Templates.GroupBy,
+	}
+}
+
+// envListOverrides maps environment variables holding comma separated lists to the field they override.
+func (c *Config) envListOverrides() map[string]*[]string {
+	return map[string]*[]string{
+		"RELEASE_NOTES_JIRA_TICKET_PATTERNS":   &c.Jira.TicketPatterns,
+		"RELEASE_NOTES_GITHUB_TICKET_PATTERNS": &c.GitHub.TicketPatterns,
+		"RELEASE_NOTES_LINEAR_TICKET_PATTERNS": &c.Linear.TicketPatterns,
+	}
+}
+
+func (c *Config) applyEnv() {
+	for env, field := range c.envOverrides() {
+		if value, ok := os.LookupEnv(env); ok {
+			*field = value
+		}
+	}
+
+	for env, field := range c.envListOverrides() {
+		if value, ok := os.LookupEnv(env); ok {
+			*field = strings.Split(value, ",")
+		}
+	}
+}
+
+// Validate checks the config is usable, the returned error is a *ValidationError.
+func (c *Config) Validate() error {
+	required := []struct {
+		key   string
+		value string
+	}{
+		{"github.org", c.GitHub.Org},
+		{"k8sEngine.repo", c.K8sEngine.Repo},
+		{"images.repoPrefix", c.Images.RepoPrefix},
+	}
+
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			return &ValidationError{Key: r.key, Reason: "must be set"}
+		}
+	}
+
+	if len(c.K8sEngine.Environments) == 0 {
+		return &ValidationError{Key: "k8sEngine.environments", Reason: "must contain at least one environment"}
+	}
+
+	templates := []struct {
+		key   string
+		path  string
+		isDir bool
+	}{
+		{"templates.dir", c.Templates.Dir, true},
+		{"templates.notes", c.Templates.Notes, false},
+		{"templates.env", c.Templates.Env, false},
+	}
+
+	for _, t := range templates {
+		if t.path == "" {
+			continue
+		}
+
+		info, err := os.Stat(t.path)
+		if err != nil {
+			return &ValidationError{Key: t.key, Reason: err.Error()}
+		}
+
+		if t.isDir && !info.IsDir() {
+			return &ValidationError{Key: t.key, Reason: fmt.Sprintf("%s must be a directory", t.path)}
+		}
+
+		if !t.isDir && info.IsDir() {
+			return &ValidationError{Key: t.key, Reason: fmt.Sprintf("%s must be a file", t.path)}
+		}
+	}
+
+	if c.Templates.GroupBy != GroupByIssue && c.Templates.GroupBy != GroupByType {
+		return &ValidationError{Key: "templates.groupBy", Reason: fmt.Sprintf("%q must be %s or %s", c.Templates.GroupBy, GroupByIssue, GroupByType)}
+	}
+
+	for i, pattern := range c.Untracked.IgnorePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return &ValidationError{Key: fmt.Sprintf("untracked.ignorePatterns[%d]", i), Reason: err.Error()}
+		}
+	}
+
+	if c.Images.NewCommits < 1 {
+		return &ValidationError{Key: "images.newCommits", Reason: fmt.Sprintf("%d must be at least 1", c.Images.NewCommits)}
+	}
+
+	// Jira caps the page size of a search at 100
+	if c.Jira.BatchSize < 1 || c.Jira.BatchSize > 100 {
+		return &ValidationError{Key: "jira.batchSize", Reason: fmt.Sprintf("%d must be between 1 and 100", c.Jira.BatchSize)}
+	}
+
+	if err := validateTracker("tracker", c.Tracker); err != nil {
+		return err
+	}
+
+	if err := validateTags("tags", c.Tags); err != nil {
+		return err
+	}
+
+	for name, repo := range c.Repos {
+		if repo.Tracker != "" {
+			if err := validateTracker(fmt.Sprintf("repos.%s.tracker", name), repo.Tracker); err != nil {
+				return err
+			}
+		}
+
+		if repo.Tags.Strategy != "" {
+			if err := validateTags(fmt.Sprintf("repos.%s.tags", name), repo.Tags); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, tracker := range c.TrackerNames() {
+		key := tracker + ".ticketPatterns"
+		patterns := c.ticketPatterns(tracker)
+		if len(patterns) == 0 {
+			return &ValidationError{Key: key, Reason: "must contain at least one pattern"}
+		}
+
+		for i, pattern := range patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return &ValidationError{Key: fmt.Sprintf("%s[%d]", key, i), Reason: err.Error()}
+			}
+		}
+
+		// the host is only needed when jira is in use
+		if tracker == TrackerJira {
+			if strings.TrimSpace(c.Jira.Host) == "" {
+				return &ValidationError{Key: "jira.host", Reason: "must be set when using jira"}
+			}
+
+			if u, err := url.Parse(c.Jira.Host); err != nil || u.Scheme == "" || u.Host == "" {
+				return &ValidationError{Key: "jira.host", Reason: fmt.Sprintf("%q is not an absolute URL", c.Jira.Host)}
+			}
+		}
+
+		if tracker == TrackerLinear && c.Linear.Workspace == "" {
+			return &ValidationError{Key: "linear.workspace", Reason: "must be set when using linear"}
+		}
+	}
+
+	return nil
+}
+
+func validateTracker(key, tracker string) error {
+	switch tracker {
+	case TrackerJira, TrackerGitHub, TrackerLinear:
+		return nil
+	}
+
+	return &ValidationError{Key: key, Reason: fmt.Sprintf("unknown tracker %q, expected one of jira, github or linear", tracker)}
+}
+
+func validateTags(key string, tags Tags) error {
+	switch tags.Strategy {
+	case TagStrategySemver, TagStrategyCalver, TagStrategySHA:
+		return nil
+	case TagStrategyRegex:
+		re, err := regexp.Compile(tags.Pattern)
+		if err != nil {
+			return &ValidationError{Key: key + ".pattern", Reason: err.Error()}
+		}
+
+		if re.NumSubexp() == 0 {
+			return &ValidationError{Key: key + ".pattern", Reason: fmt.Sprintf("%q must have a capture group", tags.Pattern)}
+		}
+
+		return nil
+	}
+
+	return &ValidationError{Key: key + ".strategy", Reason: fmt.Sprintf("unknown strategy %q, expected one of semver, calver, sha or regex", tags.Strategy)}
+}
+
+// TagsFor returns how the image tags of repo are resolved to commits.
+func (c *Config) TagsFor(repo string) Tags {
+	if r, ok := c.Repos[repo]; ok && r.Tags.Strategy != "" {
+		return r.Tags
+	}
+
+	return c.Tags
+}
+
+// TrackerFor returns the name of the issue tracker used by repo.
+func (c *Config) TrackerFor(repo string) string {
+	if r, ok := c.Repos[repo]; ok && r.Tracker != "" {
+		return r.Tracker
+	}
+
+	return c.Tracker
+}
+
+// TrackerNames returns every tracker in use, sorted.
+func (c *Config) TrackerNames() []string {
+	used := map[string]bool{c.Tracker: true}
+	for _, repo := range c.Repos {
+		if repo.Tracker != "" {
+			used[repo.Tracker] = true
+		}
+	}
+
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// TicketPatternsFor returns the patterns used to find tickets in repo, based on its tracker.
+func (c *Config) TicketPatternsFor(repo string) []string {
+	return c.ticketPatterns(c.TrackerFor(repo))
+}
+
+func (c *Config) ticketPatterns(tracker string) []string {
+	switch tracker {
+	case TrackerGitHub:
+		return c.GitHub.TicketPatterns
+	case TrackerLinear:
+		return c.Linear.TicketPatterns
+	default:
+		return c.Jira.TicketPatterns
+	}
+}
+
+// CloneURLPrefix is prepended to a repo name to clone it.
+func (c *Config) CloneURLPrefix() string {
+	if c.GitHub.CloneURL != "" {
+		return c.GitHub.CloneURL
+	}
+
+	return fmt.Sprintf("git@github.com:%s/", c.GitHub.Org)
+}
+
+// RepoURL is the web URL of a repo in the org.
+func (c *Config) RepoURL(repo string) string {
+	return fmt.Sprintf("https://github.com/%s/%s", c.GitHub.Org, repo)
+}
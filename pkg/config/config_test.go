@@ -0,0 +1,131 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), config.FileName)
+	err := os.WriteFile(path, []byte(`
+github:
+  org: other-org
+jira:
+  host: https://other.atlassian.net
+  ticketPatterns:
+  - PLAT-\d+
+  - DATA-\d+
+repos:
+  new-service:
+    tracker: github
+`), 0644)
+	require.NoError(t, err)
+
+	t.Setenv("RELEASE_NOTES_K8S_ENGINE_REPO", "deployments")
+	t.Setenv("RELEASE_NOTES_CACHE_DIR", "/var/cache/release-notes")
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, "other-org", cfg.GitHub.Org)
+	assert.Equal(t, "git@github.com:other-org/", cfg.CloneURLPrefix())
+	assert.Equal(t, config.TrackerJira, cfg.TrackerFor("old-service"))
+	assert.Equal(t, config.TrackerGitHub, cfg.TrackerFor("new-service"))
+	assert.Equal(t, []string{config.TrackerGitHub, config.TrackerJira}, cfg.TrackerNames())
+	assert.Equal(t, []string{`PLAT-\d+`, `DATA-\d+`}, cfg.Jira.TicketPatterns)
+	assert.Equal(t, "deployments", cfg.K8sEngine.Repo)
+	assert.Equal(t, "/var/cache/release-notes", cfg.Cache.Dir)
+	// unset keys keep their defaults
+	assert.Equal(t, "adarga/", cfg.Images.RepoPrefix)
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), config.FileName)
+	err := os.WriteFile(path, []byte("github:\n  organisation: typo\n"), 0644)
+	require.NoError(t, err)
+
+	_, err = config.Load(path)
+	require.ErrorContains(t, err, "organisation")
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		mutate func(*config.Config)
+		key    string
+	}{
+		{
+			mutate: func(c *config.Config) { c.GitHub.Org = "" },
+			key:    "github.org",
+		},
+		{
+			mutate: func(c *config.Config) { c.Jira.Host = "adarga.atlassian.net" },
+			key:    "jira.host",
+		},
+		{
+			mutate: func(c *config.Config) { c.Jira.TicketPatterns = []string{`APP-\d+`, "PLAT-("} },
+			key:    "jira.ticketPatterns[1]",
+		},
+		{
+			mutate: func(c *config.Config) { c.Repos = map[string]config.Repo{"svc": {Tracker: "trello"}} },
+			key:    "repos.svc.tracker",
+		},
+		{
+			mutate: func(c *config.Config) { c.Tracker = config.TrackerLinear },
+			key:    "linear.ticketPatterns",
+		},
+		{
+			mutate: func(c *config.Config) { c.Jira.BatchSize = 500 },
+			key:    "jira.batchSize",
+		},
+		{
+			mutate: func(c *config.Config) { c.K8sEngine.Environments = nil },
+			key:    "k8sEngine.environments",
+		},
+		{
+			mutate: func(c *config.Config) { c.Images.NewCommits = 0 },
+			key:    "images.newCommits",
+		},
+		{
+			mutate: func(c *config.Config) { c.Tags.Strategy = "date" },
+			key:    "tags.strategy",
+		},
+		{
+			mutate: func(c *config.Config) {
+				c.Repos = map[string]config.Repo{"svc": {Tags: config.Tags{Strategy: config.TagStrategyRegex, Pattern: `^main-[0-9a-f]+$`}}}
+			},
+			key: "repos.svc.tags.pattern",
+		},
+		{
+			mutate: func(c *config.Config) { c.Templates.GroupBy = "author" },
+			key:    "templates.groupBy",
+		},
+	}
+
+	for _, tc := range testCases {
+		cfg := config.Default()
+		tc.mutate(cfg)
+
+		err := cfg.Validate()
+		var validationErr *config.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, tc.key, validationErr.Key)
+	}
+}
+
+func TestValidateJiraHostOnlyForJira(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tracker = config.TrackerGitHub
+	cfg.Jira.Host = ""
+	require.NoError(t, cfg.Validate())
+
+	cfg.Repos = map[string]config.Repo{"svc": {Tracker: config.TrackerJira}}
+	var validationErr *config.ValidationError
+	require.ErrorAs(t, cfg.Validate(), &validationErr)
+	assert.Equal(t, "jira.host", validationErr.Key)
+}
@@ -0,0 +1,176 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// Cache holds bare mirrors of service repos on disk, so later runs only fetch what changed.
+type Cache struct {
+	Dir string
+
+	mu      sync.Mutex
+	mirrors map[string]*mirror
+}
+
+// mirror guards a single repo's mirror, fetched is only read or written while it's locked.
+type mirror struct {
+	sync.Mutex
+	fetched bool
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{
+		Dir:     dir,
+		mirrors: map[string]*mirror{},
+	}
+}
+
+// CacheFromConfig creates a cache in the configured dir, falling back to DefaultCacheDir.
+func CacheFromConfig(cfg *config.Config) (*Cache, error) {
+	dir := cfg.Cache.Dir
+	if dir == "" {
+		var err error
+		if dir, err = DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewCache(dir), nil
+}
+
+// DefaultCacheDir is ~/.cache/release-notes/repos or the equivalent for the OS.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user cache dir: %w", err)
+	}
+
+	return filepath.Join(dir, "release-notes", "repos"), nil
+}
+
+func (c *Cache) path(repo string) string {
+	return filepath.Join(c.Dir, repo+".git")
+}
+
+// lock serialises access to a single mirror, so two images built from the same
+// repo don't clone or fetch it at the same time. The mirror is returned locked.
+func (c *Cache) lock(repo string) *mirror {
+	c.mu.Lock()
+	m, ok := c.mirrors[repo]
+	if !ok {
+		m = &mirror{}
+		c.mirrors[repo] = m
+	}
+	c.mu.Unlock()
+
+	m.Lock()
+	return m
+}
+
+// Open returns the mirror of repo, cloning it on first use and fetching
+// new commits and tags the first time it's opened in this process.
+func (c *Cache) Open(auth transport.AuthMethod, url, repo string) (*git.Repository, error) {
+	m := c.lock(repo)
+	defer m.Unlock()
+
+	path := c.path(repo)
+	r, err := git.PlainOpen(path)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		r, err = git.PlainClone(path, true, &git.CloneOptions{
+			Auth:   auth,
+			URL:    url,
+			Mirror: true,
+		})
+		if err != nil {
+			// don't leave a half cloned mirror behind
+			_ = os.RemoveAll(path)
+			return nil, fmt.Errorf("failed to clone %s: %w", url, err)
+		}
+
+		m.fetched = true
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open cached repo %s: %w", path, err)
+	}
+
+	if !m.fetched {
+		err = r.Fetch(&git.FetchOptions{
+			Auth:     auth,
+			RefSpecs: []gitconfig.RefSpec{"+refs/*:refs/*"},
+			Tags:     git.AllTags,
+			Force:    true,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+		}
+
+		m.fetched = true
+	}
+
+	// the modification time of the mirror is used as its last use by Prune
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return nil, fmt.Errorf("failed to touch %s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// Prune removes mirrors that haven't been used for longer than maxAge, returning the removed repos.
+func (c *Cache) Prune(maxAge time.Duration) ([]string, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read cache dir %s: %w", c.Dir, err)
+	}
+
+	removed := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".git") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return removed, err
+		}
+
+		if time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+
+		repo := strings.TrimSuffix(entry.Name(), ".git")
+		m := c.lock(repo)
+		err = os.RemoveAll(filepath.Join(c.Dir, entry.Name()))
+		m.Unlock()
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", entry.Name(), err)
+		}
+
+		removed = append(removed, repo)
+	}
+
+	sort.Strings(removed)
+	return removed, nil
+}
+
+// Clear removes every cached mirror.
+func (c *Cache) Clear() error {
+	if err := os.RemoveAll(c.Dir); err != nil {
+		return fmt.Errorf("failed to remove cache dir %s: %w", c.Dir, err)
+	}
+
+	return nil
+}
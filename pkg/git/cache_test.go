@@ -0,0 +1,98 @@
+package git_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/git"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commits an empty change to the repo and tags it.
+func commitAndTag(t *testing.T, r *gogit.Repository, message, tag string) {
+	t.Helper()
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	hash, err := w.Commit(message, &gogit.CommitOptions{
+		AllowEmptyCommits: true,
+		Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	_, err = r.CreateTag(tag, hash, nil)
+	require.NoError(t, err)
+}
+
+func TestCache(t *testing.T) {
+	sourceDir := t.TempDir()
+	source, err := gogit.PlainInit(sourceDir, false)
+	require.NoError(t, err)
+	commitAndTag(t, source, "feat: first", "v1.0.0")
+
+	cacheDir := t.TempDir()
+	cache := git.NewCache(cacheDir)
+
+	r, err := cache.Open(nil, sourceDir, "some-service")
+	require.NoError(t, err)
+	_, err = r.Tag("v1.0.0")
+	require.NoError(t, err)
+	assert.DirExists(t, filepath.Join(cacheDir, "some-service.git"))
+
+	// a new run fetches the new tag into the existing mirror
+	commitAndTag(t, source, "feat: second", "v1.1.0")
+
+	r, err = git.NewCache(cacheDir).Open(nil, sourceDir, "some-service")
+	require.NoError(t, err)
+	_, err = r.Tag("v1.1.0")
+	require.NoError(t, err)
+
+	removed, err := cache.Prune(time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+
+	removed, err = cache.Prune(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"some-service"}, removed)
+	assert.NoDirExists(t, filepath.Join(cacheDir, "some-service.git"))
+}
+
+func TestCacheOpenConcurrently(t *testing.T) {
+	sources := map[string]string{}
+	for i := 0; i < 4; i++ {
+		dir := t.TempDir()
+		source, err := gogit.PlainInit(dir, false)
+		require.NoError(t, err)
+		commitAndTag(t, source, "feat: first", "v1.0.0")
+		sources[fmt.Sprintf("service-%d", i)] = dir
+	}
+
+	cache := git.NewCache(t.TempDir())
+
+	// every repo is opened twice at once, the second open of each shares the first's mirror
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 2*len(sources))
+	for i := 0; i < 2; i++ {
+		for repo, dir := range sources {
+			wg.Add(1)
+			go func(repo, dir string) {
+				defer wg.Done()
+				_, err := cache.Open(nil, dir, repo)
+				errs <- err
+			}(repo, dir)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
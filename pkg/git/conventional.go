@@ -0,0 +1,63 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ConventionalCommit is a commit message following https://www.conventionalcommits.org,
+// i.e feat(APP-123)!: drop the v1 api
+type ConventionalCommit struct {
+	// Type is lower cased, i.e feat, fix or perf
+	Type        string
+	Scope       string
+	Description string
+	// Breaking is set by a ! after the type or scope, or a BREAKING CHANGE footer.
+	Breaking bool
+	// BreakingNote is the text of the BREAKING CHANGE footer, empty when only marked with !
+	BreakingNote string
+}
+
+var (
+	conventionalHeader = regexp.MustCompile(`^(?P<type>[a-zA-Z]+)(?:\((?P<scope>[^()]*)\))?(?P<breaking>!)?: +(?P<description>\S.*)$`)
+	breakingFooter     = regexp.MustCompile(`^BREAKING[ -]CHANGE: *(.*)$`)
+)
+
+// ParseConventionalCommit parses message, returning false when the subject isn't a conventional commit.
+func ParseConventionalCommit(message string) (ConventionalCommit, bool) {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+
+	match := conventionalHeader.FindStringSubmatch(strings.TrimSpace(lines[0]))
+	if match == nil {
+		return ConventionalCommit{}, false
+	}
+
+	commit := ConventionalCommit{
+		Type:        strings.ToLower(match[conventionalHeader.SubexpIndex("type")]),
+		Scope:       strings.TrimSpace(match[conventionalHeader.SubexpIndex("scope")]),
+		Description: strings.TrimSpace(match[conventionalHeader.SubexpIndex("description")]),
+		Breaking:    match[conventionalHeader.SubexpIndex("breaking")] == "!",
+	}
+
+	// the footer runs until the next blank line
+	for i := 1; i < len(lines); i++ {
+		footer := breakingFooter.FindStringSubmatch(strings.TrimSpace(lines[i]))
+		if footer == nil {
+			continue
+		}
+
+		note := []string{footer[1]}
+		for _, line := range lines[i+1:] {
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+			note = append(note, strings.TrimSpace(line))
+		}
+
+		commit.Breaking = true
+		commit.BreakingNote = strings.TrimSpace(strings.Join(note, " "))
+		break
+	}
+
+	return commit, true
+}
@@ -1,13 +1,11 @@
 package git
 
 import (
-	"context"
+	"errors"
 	"fmt"
-	"log"
 	"regexp"
-	"strings"
 
-	"github.com/Masterminds/semver"
+	"github.com/alex-emery/release-notes/pkg/config"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -15,20 +13,22 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v5/storage/memory"
-	"github.com/google/go-github/v56/github"
 	"go.uber.org/zap"
 )
 
 type Auth struct {
 	Keys   *ssh.PublicKeys
 	Path   string
+	config *config.Config
+	cache  *Cache
 	logger *zap.Logger
 }
 
 // GetK8sEngineRepo either clones the repo if the path is empty or opens an existing repo.
 func (g *Auth) GetK8sEngineRepo(path string) (*git.Repository, error) {
+	// files are read from the commit trees rather than a worktree, so the cache can be used
 	if path == "" {
-		return g.CloneRepo("k8s-engine")
+		return g.CloneRepo(g.config.K8sEngine.Repo)
 	}
 
 	return g.OpenExisting(path)
@@ -38,7 +38,17 @@ func (g *Auth) OpenExisting(repo string) (*git.Repository, error) {
 	return git.PlainOpen(repo)
 }
 
+// CloneRepo clones the repo into memory, or into the on disk cache when enabled.
 func (g *Auth) CloneRepo(repo string) (*git.Repository, error) {
+	if g.cache != nil {
+		g.logger.Debug(fmt.Sprintf("Updating cached repo: %s%s", g.Path, repo))
+		return g.cache.Open(g.Keys, g.Path+repo, repo)
+	}
+
+	return g.cloneInMemory(repo)
+}
+
+func (g *Auth) cloneInMemory(repo string) (*git.Repository, error) {
 	g.logger.Debug(fmt.Sprintf("Cloning repo: %s%s", g.Path, repo))
 	return git.Clone(memory.NewStorage(), memfs.New(), &git.CloneOptions{
 		Auth: g.Keys,
@@ -46,193 +56,141 @@ func (g *Auth) CloneRepo(repo string) (*git.Repository, error) {
 	})
 }
 
-func GetTagsBetweenTags(r *git.Repository, tag1, tag2 string) ([]string, error) {
-	iter, err := r.Tags()
-	if err != nil {
-		return nil, err
-	}
-
-	versions := []*semver.Version{}
+// ErrRollback is returned when tag2 is older than tag1, the commits being rolled back are between tag2 and tag1.
+var ErrRollback = errors.New("rollback")
 
-	err = iter.ForEach(func(r *plumbing.Reference) error {
-		name := strings.TrimPrefix(r.Name().Short(), "v")
-		name = strings.TrimPrefix(name, "v")
-
-		v, err := semver.NewVersion(name)
-		if err != nil {
-			return nil
-		}
-
-		versions = append(versions, v)
-
-		return nil
-	})
-	if err != nil {
-		return nil, err
+// CommitsBetweenTags returns the commits after tag1 up to and including tag2, resolving the tags with scheme.
+// When tag2 is older than tag1 the error wraps ErrRollback.
+func CommitsBetweenTags(r *git.Repository, scheme TagScheme, tag1, tag2 string) ([]object.Commit, error) {
+	// only get commits if tag1 < tag2, when the scheme can tell
+	val, ordered := scheme.Compare(tag1, tag2)
+	if ordered && val == 0 {
+		return nil, fmt.Errorf("tag1: %s must be less than tag2: %s", tag1, tag2)
 	}
 
-	tag1 = strings.TrimPrefix(tag1, "v")
-	tag2 = strings.TrimPrefix(tag2, "v")
-
-	end, _ := semver.NewVersion(tag1)
-	start, _ := semver.NewVersion(tag2)
-
-	res := make([]string, 0)
-	for _, v := range versions {
-		if v.GreaterThan(start) && v.LessThan(end) || v.Equal(end) {
-			res = append(res, "v"+v.String())
-		}
+	if ordered && val == 1 {
+		return nil, fmt.Errorf("%w: %s is older than %s", ErrRollback, tag2, tag1)
 	}
 
-	return res, nil
-}
-
-func GetReleaseForTags(client *github.Client, repo, tag string) string {
-	tagResp, _, err := client.Repositories.GetReleaseByTag(context.Background(), "Adarga-Ltd", repo, tag)
+	startSHA, err := ResolveTag(r, scheme, tag1)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to find start SHA: %w", err)
 	}
 
-	return tagResp.GetBody()
-}
-
-func Checkout(r *git.Repository, branch *plumbing.Reference) error {
-	w, err := r.Worktree()
+	endSHA, err := ResolveTag(r, scheme, tag2)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to find end SHA: %w", err)
 	}
 
-	err = w.Checkout(&git.CheckoutOptions{
-		Branch: branch.Name(),
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed to checkout branch %s: %w", branch.Name(), err)
+	commits, err := commitsBetween(r, startSHA, endSHA)
+	if err != nil || ordered || len(commits) > 0 {
+		return commits, err
 	}
-	return nil
-}
-func CompareTags(tag1, tag2 string) (int, error) {
-	// strip off any v prefix
-	tag1 = strings.TrimPrefix(tag1, "v")
-	tag2 = strings.TrimPrefix(tag2, "v")
 
-	v1, err := semver.NewVersion(tag1)
+	// without an order, i.e SHAs, it's a rollback when tag2 is an ancestor of tag1
+	reverted, err := commitsBetween(r, endSHA, startSHA)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	v2, err := semver.NewVersion(tag2)
-	if err != nil {
-		return 0, err
+	if len(reverted) > 0 {
+		return nil, fmt.Errorf("%w: %s is an ancestor of %s", ErrRollback, tag2, tag1)
 	}
 
-	return v1.Compare(v2), nil
+	return commits, nil
 }
 
-func GetCommitsBetweenTags(r *git.Repository, tag1, tag2 string) ([]object.Commit, error) {
-	tagIter, err := r.Tags()
+// CommitsUpToTag returns up to limit commits reachable from tag, newest first,
+// used for images without a previous tag to compare against.
+func CommitsUpToTag(r *git.Repository, scheme TagScheme, tag string, limit int) ([]object.Commit, error) {
+	hash, err := ResolveTag(r, scheme, tag)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to find SHA: %w", err)
 	}
 
-	startSHA := plumbing.Hash{}
-	endSHA := plumbing.Hash{}
-
-	// only get commits if tag1 < tag2
-	val, err := CompareTags(tag1, tag2)
+	commit, err := peelToCommit(r, hash)
 	if err != nil {
 		return nil, err
 	}
 
-	if val != -1 {
-		return nil, fmt.Errorf("tag1: %s must be less than tag2: %s", tag1, tag2)
-	}
-
-	tag1 = strings.TrimLeft(tag1, "v")
-	tag2 = strings.TrimLeft(tag2, "v")
-
-	// check if the tag is missing a v prefix
-	err = tagIter.ForEach(func(r *plumbing.Reference) error {
-		name := r.Name().Short()
-		name = strings.TrimPrefix(name, "v")
-		name = strings.TrimPrefix(name, "deploy-")
-		if name == tag1 {
-			startSHA = r.Hash()
-		} else if name == tag2 {
-			endSHA = r.Hash()
+	var commits = []object.Commit{}
+	err = object.NewCommitIterCTime(commit, nil, nil).ForEach(func(c *object.Commit) error {
+		if len(commits) >= limit {
+			return storer.ErrStop
 		}
 
+		commits = append(commits, *c)
 		return nil
 	})
-
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to walk history of %s: %w", tag, err)
 	}
 
-	if startSHA.IsZero() {
-		return nil, fmt.Errorf("failed to find start SHA: %s", tag1)
+	return commits, nil
+}
+
+// commitsBetween returns the commits reachable from end but not from start, like git log start..end,
+// newest first. Either hash may be an annotated tag.
+func commitsBetween(r *git.Repository, start, end plumbing.Hash) ([]object.Commit, error) {
+	startCommit, err := peelToCommit(r, start)
+	if err != nil {
+		return nil, err
 	}
 
-	if endSHA.IsZero() {
-		return nil, fmt.Errorf("failed to find end SHA: %s", tag2)
+	endCommit, err := peelToCommit(r, end)
+	if err != nil {
+		return nil, err
 	}
 
-	cIter, err := r.Log(&git.LogOptions{
-		From: endSHA,
+	// everything reachable from start is excluded, so a start tag on a release
+	// branch or behind a merge doesn't pull in the whole history
+	excluded := map[plumbing.Hash]bool{}
+	err = object.NewCommitIterBSF(startCommit, nil, nil).ForEach(func(c *object.Commit) error {
+		excluded[c.Hash] = true
+		return nil
 	})
-
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to walk history of %s: %w", start, err)
 	}
 
 	var commits = []object.Commit{}
-	err = cIter.ForEach(func(c *object.Commit) error {
-		if c.Hash.String() == startSHA.String() {
-			return storer.ErrStop
-		}
-
+	err = object.NewCommitIterCTime(endCommit, excluded, nil).ForEach(func(c *object.Commit) error {
 		commits = append(commits, *c)
 		return nil
 	})
-
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to walk history of %s: %w", end, err)
 	}
 
 	return commits, nil
 }
 
-// uses regex to extract the ticket from the string.
-// the ticket is formatted as APP-\w{4}
-func GetTicketFromCommitMessage(message string) string {
-	re := regexp.MustCompile(`APP-\d+`)
-	return re.FindString(message)
-}
-
-// Bundles commits to the respective ticket
-type IssueCommitMap map[string][]object.Commit
-
-// Gets all unique tickets from a list of commits
-func CommitsToIssues(commits []object.Commit) IssueCommitMap {
-	commitMap := make(IssueCommitMap)
-	for _, commit := range commits {
-		line := strings.Split(commit.Message, "\n")
-		ticket := GetTicketFromCommitMessage(line[0])
-		if ticket == "" {
-			continue
+// peelToCommit returns the commit hash points at, following annotated tags.
+func peelToCommit(r *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	for {
+		tag, err := r.TagObject(hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			break
 		}
 
-		if _, ok := commitMap[ticket]; !ok {
-			commitMap[ticket] = []object.Commit{}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tag %s: %w", hash, err)
 		}
-		commitMap[ticket] = append(commitMap[ticket], commit)
+
+		hash = tag.Target
+	}
+
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
 	}
 
-	return commitMap
+	return commit, nil
 }
 
-func ExtractRepoName(line string) string {
-	var re = regexp.MustCompile(`(?m)adarga/(?P<repo>.+)`)
+// ExtractRepoName returns the part of an image name following prefix, i.e adarga/
+func ExtractRepoName(prefix, line string) string {
+	var re = regexp.MustCompile(`(?m)` + regexp.QuoteMeta(prefix) + `(?P<repo>.+)`)
 
 	match := re.FindStringSubmatch(line)
 	if len(match) == 0 {
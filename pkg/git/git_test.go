@@ -3,31 +3,74 @@ package git_test
 import (
 	"testing"
 
+	"github.com/alex-emery/release-notes/pkg/config"
 	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTicketExtraction(t *testing.T) {
+	rules, err := git.NewTicketRules([]string{`APP-\d+`, `PLAT-\d+`, `DATA-\d+`})
+	require.NoError(t, err)
+
 	testCases := []struct {
 		line     string
-		expected string
+		expected []string
 	}{
 		{
 			line:     "3eb443b feat: [APP-12431] increase max recv size for GetLeafVectors gRPC call (#138)",
-			expected: "APP-12431",
+			expected: []string{"APP-12431"},
 		},
 		{
 			line:     "3eb443b feat: APP-2117_fix_meta_parsing increase max recv size for GetLeafVectors gRPC call (#138)",
-			expected: "APP-2117",
+			expected: []string{"APP-2117"},
+		},
+		{
+			line:     "fix(PLAT-12): bump chart\n\nAlso fixes DATA-3 and APP-4\n\nRefs: PLAT-12, DATA-5\nCloses: APP-6",
+			expected: []string{"PLAT-12", "DATA-3", "APP-4", "DATA-5", "APP-6"},
+		},
+		{
+			line:     "chore: bump deps",
+			expected: []string{},
 		},
 	}
 
 	for _, tc := range testCases {
-		actual := git.GetTicketFromCommitMessage(tc.line)
+		actual := rules.Find(tc.line)
 		assert.Equal(t, tc.expected, actual)
 	}
 }
 
+func TestGitHubTicketExtraction(t *testing.T) {
+	rules, err := git.NewTicketRules(config.Default().GitHub.TicketPatterns)
+	require.NoError(t, err)
+
+	// the squash merge suffix is the PR itself, not an issue
+	assert.Equal(t, []string{}, rules.Find("fix: nil rating (#138)"))
+	assert.Equal(t, []string{"#12", "Adarga-Ltd/gateway#7"}, rules.Find("fix: nil rating (#138)\n\nFixes #12, see Adarga-Ltd/gateway#7"))
+	assert.Equal(t, []string{"#3"}, rules.Find("#3 fix the rating"))
+}
+
+func TestCommitsToIssues(t *testing.T) {
+	rules, err := git.NewTicketRules([]string{`APP-\d+`, `PLAT-\d+`})
+	require.NoError(t, err)
+
+	commits := []object.Commit{
+		{Message: "feat(APP-1): thing\n\nRefs: PLAT-2"},
+		{Message: "Merge pull request #12 from Adarga-Ltd/plat-3-fix-thing\n\nfix thing"},
+		{Message: "Merge branch 'APP-1-follow-up' into main"},
+		{Message: "chore: bump deps"},
+	}
+
+	issues := git.CommitsToIssues(rules, commits)
+
+	assert.Len(t, issues, 3)
+	assert.Len(t, issues["APP-1"], 2)
+	assert.Len(t, issues["PLAT-2"], 1)
+	assert.Len(t, issues["PLAT-3"], 1)
+}
+
 func TestExtractRepo(t *testing.T) {
 	testCases := []struct {
 		repo     string
@@ -56,7 +99,7 @@ func TestExtractRepo(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		actual := git.ExtractRepoName(tc.repo)
+		actual := git.ExtractRepoName("adarga/", tc.repo)
 		assert.Equal(t, tc.expected, actual)
 	}
 
@@ -82,3 +125,51 @@ func TestExtractPR(t *testing.T) {
 		assert.Equal(t, tc.expected, actual)
 	}
 }
+
+func TestParseConventionalCommit(t *testing.T) {
+	testCases := []struct {
+		message      string
+		expected     git.ConventionalCommit
+		conventional bool
+	}{
+		{
+			message:      "feat(APP-123): support answer rating (#174)",
+			expected:     git.ConventionalCommit{Type: "feat", Scope: "APP-123", Description: "support answer rating (#174)"},
+			conventional: true,
+		},
+		{
+			message:      "Fix: handle empty tags",
+			expected:     git.ConventionalCommit{Type: "fix", Description: "handle empty tags"},
+			conventional: true,
+		},
+		{
+			message:      "refactor(api)!: drop the v1 endpoints",
+			expected:     git.ConventionalCommit{Type: "refactor", Scope: "api", Description: "drop the v1 endpoints", Breaking: true},
+			conventional: true,
+		},
+		{
+			message: "perf: cache embeddings\n\nSome detail.\n\nBREAKING CHANGE: the cache dir must be\nwritable\n\nRefs: APP-1",
+			expected: git.ConventionalCommit{
+				Type:         "perf",
+				Description:  "cache embeddings",
+				Breaking:     true,
+				BreakingNote: "the cache dir must be writable",
+			},
+			conventional: true,
+		},
+		{
+			message:      "Merge pull request #12 from Adarga-Ltd/APP-1",
+			conventional: false,
+		},
+		{
+			message:      "APP-12 bump chart",
+			conventional: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		commit, ok := git.ParseConventionalCommit(tc.message)
+		assert.Equal(t, tc.conventional, ok, tc.message)
+		assert.Equal(t, tc.expected, commit, tc.message)
+	}
+}
@@ -1,51 +1,45 @@
 package git
 
 import (
+	"errors"
 	"fmt"
-	"io"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/yaml"
 )
 
-// grabs images and tags from the k8s-engine repo and only
-// from kustomization.yaml files.
-func GetImagesFromK8s(r *git.Repository, sourceRefs, targetRefs string) ([]ImageDiff, error) {
-	// Get the worktree
-	w, err := r.Worktree()
+// GetImagesFromK8s diffs the images in the kustomization.yaml files changed between the refs.
+// The files are read from the commit trees so the worktree is never touched, refs can be
+// branches, remote branches such as origin/main, tags or SHAs.
+func GetImagesFromK8s(r *git.Repository, sourceRef, targetRef string) ([]ImageDiff, error) {
+	source, err := ResolveCommit(r, sourceRef)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get worktree: %w", err)
+		return nil, err
 	}
 
-	// check out source
-	err = w.Checkout(&git.CheckoutOptions{
-		Branch: plumbing.ReferenceName(sourceRefs),
-	})
+	target, err := ResolveCommit(r, targetRef)
 	if err != nil {
-		return nil, fmt.Errorf("failed to checkout branch %s: %w", sourceRefs, err)
+		return nil, err
 	}
 
-	changedFiles, err := GetChangedKustomizations(r, targetRefs)
+	changedFiles, err := GetChangedKustomizations(source, target)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get files: %w", err)
 	}
 
-	sourceYaml, err := ParseYamls(w, changedFiles)
+	sourceYaml, err := ParseYamls(source, changedFiles)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse source yaml: %w", err)
 	}
 
-	err = w.Checkout(&git.CheckoutOptions{
-		Branch: plumbing.ReferenceName(targetRefs),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to checkout branch %s: %w", targetRefs, err)
-	}
-
-	targetYaml, err := ParseYamls(w, changedFiles)
+	targetYaml, err := ParseYamls(target, changedFiles)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse target yaml: %w", err)
 	}
@@ -53,58 +47,261 @@ func GetImagesFromK8s(r *git.Repository, sourceRefs, targetRefs string) ([]Image
 	return DiffKustomizations(sourceYaml, targetYaml), nil
 }
 
+// ChangeKind is how an image changed between two kustomizations.
+type ChangeKind string
+
+const (
+	ImageAdded    ChangeKind = "added"
+	ImageRemoved  ChangeKind = "removed"
+	ImageRetagged ChangeKind = "retagged"
+	// ImageRenamed is a changed newName, the tag may have changed too.
+	ImageRenamed ChangeKind = "renamed"
+	ImageDigest  ChangeKind = "digest"
+)
+
 type ImageDiff struct {
+	// Path is the kustomization.yaml the image is set in.
+	Path string
+	// Environment and Namespace are taken from the path, see OverlayFor.
+	Environment string
+	Namespace   string
+	// Name is the image name matched by kustomize, i.e adarga/some-service
 	Name string
-	Tag1 string
-	Tag2 string
+	Kind ChangeKind
+	// NewName1 and NewName2 are the newName replacing Name, if any.
+	NewName1 string
+	NewName2 string
+	// Tag1 is empty for added images, Tag2 for removed ones.
+	Tag1    string
+	Tag2    string
+	Digest1 string
+	Digest2 string
+}
+
+// Image1 is the image deployed before the change, empty when added.
+func (d ImageDiff) Image1() string {
+	if d.Kind == ImageAdded {
+		return ""
+	}
+
+	if d.NewName1 != "" {
+		return d.NewName1
+	}
+
+	return d.Name
+}
+
+// Image2 is the image deployed after the change, empty when removed.
+func (d ImageDiff) Image2() string {
+	if d.Kind == ImageRemoved {
+		return ""
+	}
+
+	if d.NewName2 != "" {
+		return d.NewName2
+	}
+
+	return d.Name
+}
+
+// Overlay is where the change is deployed, i.e prod / wb-lfqa, or the directory when it's unknown.
+func (d ImageDiff) Overlay() string {
+	if d.Environment == "" || d.Namespace == "" {
+		return d.Environment + d.Namespace
+	}
+
+	return d.Environment + " / " + d.Namespace
 }
 
-func DiffKustomizations(original, dest []*types.Kustomization) []ImageDiff {
+// overlays are laid out as environments/engine-<env>/.../<namespace>/kustomization.yaml
+var overlayPattern = regexp.MustCompile(`^environments/engine-([^/]+)/(?:(?:[^/]+/)*([^/]+)/)?kustomization\.yaml$`)
+
+// OverlayFor returns the environment and namespace of a kustomization.yaml, the environment is empty
+// when the path isn't under environments/ and the namespace is then the file's directory.
+func OverlayFor(filepath string) (environment string, namespace string) {
+	match := overlayPattern.FindStringSubmatch(filepath)
+	if match == nil {
+		return "", path.Dir(filepath)
+	}
+
+	return match[1], match[2]
+}
+
+// DiffKustomizations compares the images of the kustomizations with the same path, by image name.
+// A path missing from original has all of its images added, one missing from dest has them all removed.
+func DiffKustomizations(original, dest map[string]*types.Kustomization) []ImageDiff {
+	paths := []string{}
+	for filepath := range original {
+		paths = append(paths, filepath)
+	}
+	for filepath := range dest {
+		if _, ok := original[filepath]; !ok {
+			paths = append(paths, filepath)
+		}
+	}
+	sort.Strings(paths)
+
 	results := []ImageDiff{}
-	for i := range original {
-		// assuming they're in the same order...
-		for _, oimg := range original[i].Images {
-			for _, dimg := range dest[i].Images {
-				if oimg.Name == dimg.Name && oimg.NewTag != dimg.NewTag {
-					results = append(results, ImageDiff{
-						Name: oimg.Name,
-						Tag1: oimg.NewTag,
-						Tag2: dimg.NewTag,
-					})
-				}
+	for _, filepath := range paths {
+		environment, namespace := OverlayFor(filepath)
+		for _, diff := range diffImages(imagesOf(original[filepath]), imagesOf(dest[filepath])) {
+			diff.Path = filepath
+			diff.Environment = environment
+			diff.Namespace = namespace
+			results = append(results, diff)
+		}
+	}
+
+	return results
+}
+
+func imagesOf(k *types.Kustomization) []types.Image {
+	if k == nil {
+		return nil
+	}
+
+	return k.Images
+}
+
+// diffImages matches the images by name. A name is listed once per workload in rendered overlays, the
+// images unchanged on both sides are dropped and the rest paired in order. Workloads making the same
+// change are reported once.
+func diffImages(original, dest []types.Image) []ImageDiff {
+	results := []ImageDiff{}
+	seen := map[ImageDiff]bool{}
+	add := func(diff ImageDiff) {
+		if !seen[diff] {
+			seen[diff] = true
+			results = append(results, diff)
+		}
+	}
+
+	names := []string{}
+	originalByName := map[string][]types.Image{}
+	destByName := map[string][]types.Image{}
+	for _, oimg := range original {
+		if _, ok := originalByName[oimg.Name]; !ok {
+			names = append(names, oimg.Name)
+		}
+		originalByName[oimg.Name] = append(originalByName[oimg.Name], oimg)
+	}
+	for _, dimg := range dest {
+		if _, ok := originalByName[dimg.Name]; !ok {
+			if _, ok := destByName[dimg.Name]; !ok {
+				names = append(names, dimg.Name)
 			}
 		}
+		destByName[dimg.Name] = append(destByName[dimg.Name], dimg)
+	}
+
+	for _, name := range names {
+		originals, dests := withoutUnchanged(originalByName[name], destByName[name])
+		for i := 0; i < len(originals) && i < len(dests); i++ {
+			oimg, dimg := originals[i], dests[i]
+			diff := ImageDiff{
+				Name:     name,
+				NewName1: oimg.NewName,
+				NewName2: dimg.NewName,
+				Tag1:     oimg.NewTag,
+				Tag2:     dimg.NewTag,
+				Digest1:  oimg.Digest,
+				Digest2:  dimg.Digest,
+			}
+
+			switch {
+			case oimg.NewName != dimg.NewName:
+				diff.Kind = ImageRenamed
+			case oimg.NewTag != dimg.NewTag:
+				diff.Kind = ImageRetagged
+			default:
+				diff.Kind = ImageDigest
+			}
+
+			add(diff)
+		}
+
+		for _, oimg := range originals[min(len(originals), len(dests)):] {
+			add(ImageDiff{
+				Name:     name,
+				Kind:     ImageRemoved,
+				NewName1: oimg.NewName,
+				Tag1:     oimg.NewTag,
+				Digest1:  oimg.Digest,
+			})
+		}
+
+		for _, dimg := range dests[min(len(originals), len(dests)):] {
+			add(ImageDiff{
+				Name:     name,
+				Kind:     ImageAdded,
+				NewName2: dimg.NewName,
+				Tag2:     dimg.NewTag,
+				Digest2:  dimg.Digest,
+			})
+		}
 	}
 
 	return results
 }
 
-func ParseYamls(w *git.Worktree, filepath []string) ([]*types.Kustomization, error) {
-	yamlFiles := []*types.Kustomization{}
+// withoutUnchanged drops the images found on both sides.
+func withoutUnchanged(original, dest []types.Image) ([]types.Image, []types.Image) {
+	remaining := append([]types.Image{}, dest...)
+	changed := []types.Image{}
+	for _, oimg := range original {
+		found := false
+		for i, dimg := range remaining {
+			if oimg.NewName == dimg.NewName && oimg.NewTag == dimg.NewTag && oimg.Digest == dimg.Digest {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			changed = append(changed, oimg)
+		}
+	}
+
+	return changed, remaining
+}
+
+// ParseYamls parses the kustomizations in the commit keyed by path, files that don't exist are left out.
+func ParseYamls(commit *object.Commit, filepath []string) (map[string]*types.Kustomization, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree for %s: %w", commit.Hash, err)
+	}
+
+	yamlFiles := map[string]*types.Kustomization{}
 	for _, file := range filepath {
-		k, err := ParseYaml(w, file)
+		k, err := ParseYaml(tree, file)
+		if errors.Is(err, object.ErrFileNotFound) {
+			continue
+		}
+
 		if err != nil {
 			return nil, err
 		}
-		yamlFiles = append(yamlFiles, k)
+		yamlFiles[file] = k
 	}
 
 	return yamlFiles, nil
 }
 
-func ParseYaml(w *git.Worktree, filepath string) (*types.Kustomization, error) {
-	file, err := w.Filesystem.Open(filepath)
+func ParseYaml(tree *object.Tree, filepath string) (*types.Kustomization, error) {
+	file, err := tree.File(filepath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file %s: %w", filepath, err)
 	}
 
-	data, err := io.ReadAll(file)
+	data, err := file.Contents()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", filepath, err)
 	}
 
 	k := &types.Kustomization{}
-	err = yaml.Unmarshal(data, k)
+	err = yaml.Unmarshal([]byte(data), k)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal file %s: %w", filepath, err)
 	}
@@ -112,48 +309,24 @@ func ParseYaml(w *git.Worktree, filepath string) (*types.Kustomization, error) {
 	return k, nil
 }
 
-func GetChangedKustomizations(r *git.Repository, branch string) ([]string, error) {
-	// Get the HEAD reference
-	headRef, err := r.Head()
-	if err != nil {
-		return nil, err
-	}
-
-	// Get the commit for the HEAD
-	headCommit, err := r.CommitObject(headRef.Hash())
-	if err != nil {
-		return nil, err
-	}
-
-	// Get the branch reference
-	branchRef, err := r.Reference(plumbing.ReferenceName(branch), true)
+// GetChangedKustomizations lists the kustomization.yaml files that differ between the commits.
+func GetChangedKustomizations(from, to *object.Commit) ([]string, error) {
+	changes, err := from.Patch(to)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to diff %s and %s: %w", from.Hash, to.Hash, err)
 	}
-
-	// Get the commit for the branch
-	branchCommit, err := r.CommitObject(branchRef.Hash())
-	if err != nil {
-		return nil, err
-	}
-
-	// Get the diff between the HEAD and the branch
-	changes, err := headCommit.Patch(branchCommit)
-	if err != nil {
-		return nil, err
-	}
-
+	// both sides are kept so moved files are read from each branch
+	seen := map[string]bool{}
 	changedFiles := []string{}
 	for _, change := range changes.FilePatches() {
 		from, to := change.Files()
-		if from != nil {
-			if strings.HasSuffix(from.Path(), "kustomization.yaml") {
-				changedFiles = append(changedFiles, from.Path())
-			}
-		} else {
-			if strings.HasSuffix(to.Path(), "kustomization.yaml") {
-				changedFiles = append(changedFiles, to.Path())
+		for _, file := range []diff.File{from, to} {
+			if file == nil || seen[file.Path()] || !strings.HasSuffix(file.Path(), "kustomization.yaml") {
+				continue
 			}
+
+			seen[file.Path()] = true
+			changedFiles = append(changedFiles, file.Path())
 		}
 	}
 
@@ -0,0 +1,157 @@
+package git_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/git"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func TestDiffKustomizations(t *testing.T) {
+	const prod = "environments/engine-prod/baseline/wb-lfqa/kustomization.yaml"
+	const deleted = "environments/engine-stage/baseline/wb-old/kustomization.yaml"
+	const created = "environments/engine-stage/baseline/wb-new/kustomization.yaml"
+
+	original := map[string]*types.Kustomization{prod: {Images: []types.Image{
+		{Name: "adarga/unchanged", NewTag: "1.0.0"},
+		{Name: "adarga/retagged", NewTag: "1.0.0"},
+		{Name: "adarga/renamed", NewName: "old.registry/adarga/renamed", NewTag: "1.0.0"},
+		{Name: "adarga/pinned", NewTag: "1.0.0", Digest: "sha256:aaa"},
+		{Name: "adarga/removed", NewTag: "2.0.0"},
+	}}, deleted: {Images: []types.Image{
+		{Name: "adarga/some-service", NewTag: "1.0.0"},
+	}}}
+
+	dest := map[string]*types.Kustomization{prod: {Images: []types.Image{
+		{Name: "adarga/added", NewTag: "0.1.0"},
+		{Name: "adarga/unchanged", NewTag: "1.0.0"},
+		{Name: "adarga/retagged", NewTag: "1.1.0"},
+		{Name: "adarga/renamed", NewName: "new.registry/adarga/renamed", NewTag: "1.0.0"},
+		{Name: "adarga/pinned", NewTag: "1.0.0", Digest: "sha256:bbb"},
+	}}, created: {Images: []types.Image{
+		{Name: "adarga/some-service", NewTag: "1.1.0"},
+	}}}
+
+	diffs := git.DiffKustomizations(original, dest)
+
+	// ordered by path, then by image
+	assert.Equal(t, []git.ImageDiff{
+		{Path: prod, Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/retagged", Kind: git.ImageRetagged, Tag1: "1.0.0", Tag2: "1.1.0"},
+		{Path: prod, Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/renamed", Kind: git.ImageRenamed, NewName1: "old.registry/adarga/renamed", NewName2: "new.registry/adarga/renamed", Tag1: "1.0.0", Tag2: "1.0.0"},
+		{Path: prod, Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/pinned", Kind: git.ImageDigest, Tag1: "1.0.0", Tag2: "1.0.0", Digest1: "sha256:aaa", Digest2: "sha256:bbb"},
+		{Path: prod, Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/removed", Kind: git.ImageRemoved, Tag1: "2.0.0"},
+		{Path: prod, Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/added", Kind: git.ImageAdded, Tag2: "0.1.0"},
+		{Path: created, Environment: "stage", Namespace: "wb-new", Name: "adarga/some-service", Kind: git.ImageAdded, Tag2: "1.1.0"},
+		{Path: deleted, Environment: "stage", Namespace: "wb-old", Name: "adarga/some-service", Kind: git.ImageRemoved, Tag1: "1.0.0"},
+	}, diffs)
+
+	assert.Equal(t, "prod / wb-lfqa", diffs[0].Overlay())
+
+	assert.Equal(t, "old.registry/adarga/renamed", diffs[1].Image1())
+	assert.Equal(t, "new.registry/adarga/renamed", diffs[1].Image2())
+	assert.Equal(t, "adarga/removed", diffs[3].Image1())
+	assert.Empty(t, diffs[3].Image2())
+	assert.Empty(t, diffs[4].Image1())
+	assert.Equal(t, "adarga/added", diffs[4].Image2())
+}
+
+func TestOverlayFor(t *testing.T) {
+	testCases := []struct {
+		path        string
+		environment string
+		namespace   string
+	}{
+		{path: "environments/engine-prod/baseline/wb-lfqa/kustomization.yaml", environment: "prod", namespace: "wb-lfqa"},
+		{path: "environments/engine-dev/wb-lfqa/kustomization.yaml", environment: "dev", namespace: "wb-lfqa"},
+		{path: "environments/engine-dev/kustomization.yaml", environment: "dev", namespace: ""},
+		{path: "base/some-service/kustomization.yaml", environment: "", namespace: "base/some-service"},
+	}
+
+	for _, tc := range testCases {
+		environment, namespace := git.OverlayFor(tc.path)
+		assert.Equal(t, tc.environment, environment, tc.path)
+		assert.Equal(t, tc.namespace, namespace, tc.path)
+	}
+}
+
+// commitFiles writes the files into the worktree and commits them, returning the commit.
+func commitFiles(t *testing.T, r *gogit.Repository, dir string, files map[string]string) plumbing.Hash {
+	t.Helper()
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	for name, contents := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+		_, err = w.Add(name)
+		require.NoError(t, err)
+	}
+
+	hash, err := w.Commit("update images", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	return hash
+}
+
+func TestGetImagesFromK8s(t *testing.T) {
+	const prod = "environments/engine-prod/baseline/wb-lfqa/kustomization.yaml"
+	const stage = "environments/engine-stage/baseline/wb-lfqa/kustomization.yaml"
+
+	dir := t.TempDir()
+	r, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	commitFiles(t, r, dir, map[string]string{
+		prod: "images:\n- name: adarga/some-service\n  newTag: 1.0.0\n",
+	})
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release"), Create: true}))
+	release := commitFiles(t, r, dir, map[string]string{
+		prod:  "images:\n- name: adarga/some-service\n  newTag: 1.1.0\n",
+		stage: "images:\n- name: adarga/some-service\n  newTag: 1.1.0\n",
+	})
+	require.NoError(t, w.Checkout(&gogit.CheckoutOptions{Branch: plumbing.Master}))
+
+	// the release branch only exists on the remote
+	require.NoError(t, r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "release"), release)))
+	require.NoError(t, r.Storer.RemoveReference(plumbing.NewBranchReferenceName("release")))
+
+	// uncommitted changes must survive
+	dirty := "images:\n- name: adarga/some-service\n  newTag: local-edit\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, prod), []byte(dirty), 0644))
+
+	expected := []git.ImageDiff{
+		{Path: prod, Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/some-service", Kind: git.ImageRetagged, Tag1: "1.0.0", Tag2: "1.1.0"},
+		{Path: stage, Environment: "stage", Namespace: "wb-lfqa", Name: "adarga/some-service", Kind: git.ImageAdded, Tag2: "1.1.0"},
+	}
+
+	for _, target := range []string{"origin/release", release.String()} {
+		diffs, err := git.GetImagesFromK8s(r, "master", target)
+		require.NoError(t, err, target)
+		assert.Equal(t, expected, diffs, target)
+	}
+
+	head, err := r.Head()
+	require.NoError(t, err)
+	assert.Equal(t, plumbing.Master, head.Name())
+
+	contents, err := os.ReadFile(filepath.Join(dir, prod))
+	require.NoError(t, err)
+	assert.Equal(t, dirty, string(contents))
+
+	_, err = git.GetImagesFromK8s(r, "master", "missing")
+	assert.ErrorContains(t, err, "failed to resolve missing")
+}
@@ -0,0 +1,142 @@
+package git_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixture builds an in-memory history, each commit an hour after the last.
+type fixture struct {
+	t    *testing.T
+	r    *gogit.Repository
+	when time.Time
+	// commits are keyed by message.
+	commits map[string]plumbing.Hash
+}
+
+func newFixture(t *testing.T) *fixture {
+	r, err := gogit.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+
+	return &fixture{
+		t:       t,
+		r:       r,
+		when:    time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC),
+		commits: map[string]plumbing.Hash{},
+	}
+}
+
+// commit creates a commit on top of parents, or HEAD when there are none.
+func (f *fixture) commit(message string, parents ...string) {
+	f.t.Helper()
+
+	w, err := f.r.Worktree()
+	require.NoError(f.t, err)
+
+	hashes := []plumbing.Hash{}
+	for _, parent := range parents {
+		hashes = append(hashes, f.commits[parent])
+	}
+
+	f.when = f.when.Add(time.Hour)
+	signature := &object.Signature{Name: "test", Email: "test@example.com", When: f.when}
+	hash, err := w.Commit(message, &gogit.CommitOptions{
+		AllowEmptyCommits: true,
+		Author:            signature,
+		Committer:         signature,
+		Parents:           hashes,
+	})
+	require.NoError(f.t, err)
+
+	f.commits[message] = hash
+}
+
+// tag creates an annotated tag, like git tag -a
+func (f *fixture) tag(name, message string) {
+	f.t.Helper()
+
+	_, err := f.r.CreateTag(name, f.commits[message], &gogit.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: f.when},
+		Message: name,
+	})
+	require.NoError(f.t, err)
+}
+
+func (f *fixture) between(tag1, tag2 string) []string {
+	f.t.Helper()
+
+	scheme, err := git.NewTagScheme(config.Default().Tags)
+	require.NoError(f.t, err)
+
+	commits, err := git.CommitsBetweenTags(f.r, scheme, tag1, tag2)
+	require.NoError(f.t, err)
+
+	messages := []string{}
+	for _, commit := range commits {
+		messages = append(messages, commit.Message)
+	}
+
+	return messages
+}
+
+func TestCommitsBetweenMerges(t *testing.T) {
+	f := newFixture(t)
+	f.commit("initial")
+	f.tag("v1.0.0", "initial")
+	f.commit("feature one", "initial")
+	f.commit("main one", "initial")
+	f.commit("feature two", "feature one")
+	f.commit("merge feature", "main one", "feature two")
+	f.tag("v1.1.0", "merge feature")
+
+	assert.Equal(t, []string{"merge feature", "feature two", "main one", "feature one"}, f.between("v1.0.0", "v1.1.0"))
+}
+
+func TestCommitsBetweenHotfix(t *testing.T) {
+	f := newFixture(t)
+	f.commit("old history")
+	f.commit("initial", "old history")
+	f.tag("v1.0.0", "initial")
+	f.commit("main one", "initial")
+	f.commit("hotfix", "initial")
+	f.tag("v1.0.1", "hotfix")
+	f.commit("main two", "main one")
+	f.tag("v1.1.0", "main two")
+
+	// the hotfix isn't an ancestor of v1.1.0, the old history must not be listed
+	assert.Equal(t, []string{"main two", "main one"}, f.between("v1.0.1", "v1.1.0"))
+	assert.Equal(t, []string{"hotfix"}, f.between("v1.0.0", "v1.0.1"))
+
+	// the hotfix is merged back, it's reachable from v1.0.1 so isn't listed again
+	f.commit("merge hotfix", "main two", "hotfix")
+	f.tag("v1.2.0", "merge hotfix")
+	assert.Equal(t, []string{"merge hotfix", "main two", "main one"}, f.between("v1.0.1", "v1.2.0"))
+}
+
+func TestCommitsUpToTag(t *testing.T) {
+	f := newFixture(t)
+	f.commit("initial")
+	f.commit("one")
+	f.commit("two")
+	f.tag("v0.1.0", "two")
+	f.commit("unreleased")
+
+	scheme, err := git.NewTagScheme(config.Default().Tags)
+	require.NoError(t, err)
+
+	commits, err := git.CommitsUpToTag(f.r, scheme, "0.1.0", 2)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, "two", commits[0].Message)
+	assert.Equal(t, "one", commits[1].Message)
+}
@@ -0,0 +1,145 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// DefaultRemote is fetched before comparing branches, and used as the upstream of branches without one.
+const DefaultRemote = "origin"
+
+// Fetch updates the remote-tracking branches and tags of a local repo, like git fetch.
+func (g *Auth) Fetch(r *git.Repository, remoteName string) error {
+	remote, err := r.Remote(remoteName)
+	if err != nil {
+		return fmt.Errorf("failed to find remote %s: %w", remoteName, err)
+	}
+
+	// the SSH keys can't be used for a remote cloned over HTTPS
+	var auth transport.AuthMethod
+	if urls := remote.Config().URLs; len(urls) > 0 && !strings.HasPrefix(urls[0], "http") && g.Keys != nil {
+		auth = g.Keys
+	}
+
+	err = remote.Fetch(&git.FetchOptions{
+		Auth: auth,
+		Tags: git.AllTags,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("failed to fetch %s: %w", remoteName, err)
+	}
+
+	return nil
+}
+
+// ResolveCommit resolves ref as, in order, a local branch, a remote-tracking branch (origin/main),
+// a branch that only exists on the default remote, a tag and finally a full or abbreviated SHA.
+func ResolveCommit(r *git.Repository, ref string) (*object.Commit, error) {
+	candidates := []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.ReferenceName("refs/remotes/" + ref),
+		plumbing.NewRemoteReferenceName(DefaultRemote, ref),
+		plumbing.NewTagReferenceName(ref),
+	}
+
+	for _, name := range candidates {
+		resolved, err := r.Reference(name, true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+
+		return peelToCommit(r, resolved.Hash())
+	}
+
+	hash, err := resolveCommit(r, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s as a branch, tag or SHA: %w", ref, err)
+	}
+
+	return peelToCommit(r, hash)
+}
+
+// BranchName is the name GitHub knows a branch by, a remote-tracking branch loses its remote, i.e origin/main is main.
+// Tags and SHAs aren't branches, so a PR can't be opened from or into them.
+func BranchName(r *git.Repository, ref string) (string, error) {
+	candidates := []struct {
+		name   plumbing.ReferenceName
+		branch string
+	}{
+		{name: plumbing.NewBranchReferenceName(ref), branch: ref},
+		{name: plumbing.ReferenceName("refs/remotes/" + ref), branch: ref[strings.Index(ref, "/")+1:]},
+		{name: plumbing.NewRemoteReferenceName(DefaultRemote, ref), branch: ref},
+	}
+
+	for _, candidate := range candidates {
+		_, err := r.Reference(candidate.name, true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", candidate.name, err)
+		}
+
+		return candidate.branch, nil
+	}
+
+	if _, err := r.Reference(plumbing.NewTagReferenceName(ref), true); err == nil {
+		return "", fmt.Errorf("%s is a tag, a PR can only be opened between branches", ref)
+	}
+
+	return "", fmt.Errorf("%s isn't a branch, a PR can only be opened between branches", ref)
+}
+
+// Upstream is a local branch compared with the remote branch it tracks.
+type Upstream struct {
+	// Name is the remote-tracking branch, i.e origin/main
+	Name string
+	// Behind is the number of upstream commits missing from the local branch.
+	Behind int
+}
+
+// UpstreamOf compares a local branch with its upstream, falling back to the same branch on the
+// default remote when none is configured. ok is false when branch isn't a local branch or there's
+// no upstream to compare with.
+func UpstreamOf(r *git.Repository, branch string) (upstream Upstream, ok bool, err error) {
+	local, err := r.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return Upstream{}, false, nil
+	}
+
+	if err != nil {
+		return Upstream{}, false, fmt.Errorf("failed to resolve branch %s: %w", branch, err)
+	}
+
+	remoteName, merge := DefaultRemote, branch
+	if cfg, err := r.Branch(branch); err == nil && cfg.Remote != "" {
+		remoteName, merge = cfg.Remote, cfg.Merge.Short()
+	}
+
+	remote, err := r.Reference(plumbing.NewRemoteReferenceName(remoteName, merge), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return Upstream{}, false, nil
+	}
+
+	if err != nil {
+		return Upstream{}, false, fmt.Errorf("failed to resolve upstream of %s: %w", branch, err)
+	}
+
+	missing, err := commitsBetween(r, local.Hash(), remote.Hash())
+	if err != nil {
+		return Upstream{}, false, fmt.Errorf("failed to compare %s with its upstream: %w", branch, err)
+	}
+
+	return Upstream{Name: remote.Name().Short(), Behind: len(missing)}, true, nil
+}
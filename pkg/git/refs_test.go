@@ -0,0 +1,91 @@
+package git_test
+
+import (
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/git"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchAndResolve(t *testing.T) {
+	sourceDir := t.TempDir()
+	source, err := gogit.PlainInit(sourceDir, false)
+	require.NoError(t, err)
+	commitAndTag(t, source, "feat: first", "v1.0.0")
+
+	clone, err := gogit.PlainClone(t.TempDir(), false, &gogit.CloneOptions{URL: sourceDir})
+	require.NoError(t, err)
+
+	commitAndTag(t, source, "feat: second", "v1.1.0")
+	second, err := source.Head()
+	require.NoError(t, err)
+
+	w, err := source.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}))
+	commitAndTag(t, source, "feat: unreleased", "v1.2.0-rc.1")
+	feature, err := source.Head()
+	require.NoError(t, err)
+
+	// nothing is known about the new commits until the remote is fetched
+	upstream, ok, err := git.UpstreamOf(clone, "master")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Zero(t, upstream.Behind)
+
+	auth := &git.Auth{}
+	require.NoError(t, auth.Fetch(clone, git.DefaultRemote))
+	require.NoError(t, auth.Fetch(clone, git.DefaultRemote), "already up to date")
+
+	upstream, ok, err = git.UpstreamOf(clone, "master")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, git.Upstream{Name: "origin/master", Behind: 1}, upstream)
+
+	_, ok, err = git.UpstreamOf(clone, "feature")
+	require.NoError(t, err)
+	assert.False(t, ok, "feature isn't a local branch")
+
+	local, err := clone.Head()
+	require.NoError(t, err)
+
+	// the stale local master is resolved before the remote branch
+	testCases := map[string]plumbing.Hash{
+		"master":                   local.Hash(),
+		"origin/master":            second.Hash(),
+		"feature":                  feature.Hash(),
+		"v1.1.0":                   second.Hash(),
+		second.Hash().String()[:7]: second.Hash(),
+	}
+
+	for ref, expected := range testCases {
+		commit, err := git.ResolveCommit(clone, ref)
+		require.NoError(t, err, ref)
+		assert.Equal(t, expected, commit.Hash, ref)
+	}
+
+	_, err = git.ResolveCommit(clone, "missing")
+	assert.ErrorContains(t, err, "failed to resolve missing")
+
+	// PRs are opened between the branches GitHub knows
+	branches := map[string]string{
+		"master":        "master",
+		"origin/master": "master",
+		"feature":       "feature",
+	}
+
+	for ref, expected := range branches {
+		branch, err := git.BranchName(clone, ref)
+		require.NoError(t, err, ref)
+		assert.Equal(t, expected, branch, ref)
+	}
+
+	_, err = git.BranchName(clone, "v1.1.0")
+	assert.ErrorContains(t, err, "v1.1.0 is a tag")
+
+	_, err = git.BranchName(clone, second.Hash().String())
+	assert.ErrorContains(t, err, "isn't a branch")
+}
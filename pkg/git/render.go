@@ -0,0 +1,388 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// workloadContainers are the paths to the pod spec of each kind of workload whose images are compared.
+var workloadContainers = map[string][]string{
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// RenderImagesFromK8s builds the overlays affected by the change with kustomize at both refs and diffs the
+// images of the rendered workloads, so changes made in bases, components, patches or replacements are included.
+// Each overlay is reported against its kustomization.yaml, like GetImagesFromK8s. An overlay that fails to
+// build at either ref, i.e it uses a remote base, is logged and left out rather than failing the rest.
+func RenderImagesFromK8s(logger *zap.Logger, r *git.Repository, sourceRef, targetRef string) ([]ImageDiff, error) {
+	source, err := ResolveCommit(r, sourceRef)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := ResolveCommit(r, targetRef)
+	if err != nil {
+		return nil, err
+	}
+
+	if source.Hash == target.Hash {
+		return []ImageDiff{}, nil
+	}
+
+	overlays, err := AffectedOverlays(source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("rendering overlays", zap.Strings("overlays", overlays))
+
+	sourceImages, sourceFailed, err := RenderOverlays(source, overlays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", sourceRef, err)
+	}
+
+	targetImages, targetFailed, err := RenderOverlays(target, overlays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", targetRef, err)
+	}
+
+	// an overlay that only built at one ref would look added or removed
+	for _, failed := range []map[string]error{sourceFailed, targetFailed} {
+		for overlay, err := range failed {
+			logger.Warn("failed to render overlay, skipping", zap.String("overlay", overlay), zap.Error(err))
+			delete(sourceImages, overlay)
+			delete(targetImages, overlay)
+		}
+	}
+
+	return DiffKustomizations(sourceImages, targetImages), nil
+}
+
+// AffectedOverlays returns the overlays in either commit whose build inputs changed between them, sorted.
+// The inputs are found by following the local paths of each kustomization the overlay includes.
+func AffectedOverlays(from, to *object.Commit) ([]string, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree for %s: %w", from.Hash, err)
+	}
+
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree for %s: %w", to.Hash, err)
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s and %s: %w", from.Hash, to.Hash, err)
+	}
+
+	changed := []string{}
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" {
+				changed = append(changed, name)
+			}
+		}
+	}
+
+	affected := map[string]bool{}
+	for _, tree := range []*object.Tree{fromTree, toTree} {
+		kustomizations, err := kustomizationPaths(tree)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, overlay := range leafOverlays(kustomizations) {
+			if affected[overlay] {
+				continue
+			}
+
+			inputs := map[string]bool{}
+			overlayInputs(tree, path.Dir(overlay), inputs)
+			affected[overlay] = touches(inputs, changed)
+		}
+	}
+
+	overlays := []string{}
+	for overlay, ok := range affected {
+		if ok {
+			overlays = append(overlays, overlay)
+		}
+	}
+	sort.Strings(overlays)
+
+	return overlays, nil
+}
+
+// kustomizationPaths lists every kustomization.yaml in the tree.
+func kustomizationPaths(tree *object.Tree) ([]string, error) {
+	paths := []string{}
+	err := tree.Files().ForEach(func(f *object.File) error {
+		if path.Base(f.Name) == "kustomization.yaml" {
+			paths = append(paths, f.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list kustomizations: %w", err)
+	}
+
+	return paths, nil
+}
+
+// overlayInputs adds the directory of the kustomization in dir, suffixed with /, to inputs along with
+// every local file and kustomization it references. Remote resources are left out, their URL is pinned
+// in the kustomization so a change to them is a change to the file.
+func overlayInputs(tree *object.Tree, dir string, inputs map[string]bool) {
+	if inputs[dir+"/"] {
+		return
+	}
+	inputs[dir+"/"] = true
+
+	k, err := ParseYaml(tree, path.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		return
+	}
+	k.FixKustomization()
+
+	refs := append([]string{}, k.Resources...)
+	refs = append(refs, k.Components...)
+	refs = append(refs, k.Crds...)
+	for _, patch := range append(k.Patches, k.PatchesJson6902...) {
+		refs = append(refs, patch.Path)
+	}
+	for _, patch := range k.PatchesStrategicMerge {
+		refs = append(refs, string(patch))
+	}
+	for _, generator := range k.ConfigMapGenerator {
+		refs = append(refs, generatorFiles(generator.GeneratorArgs)...)
+	}
+	for _, generator := range k.SecretGenerator {
+		refs = append(refs, generatorFiles(generator.GeneratorArgs)...)
+	}
+
+	for _, ref := range refs {
+		if ref == "" || strings.Contains(ref, "://") || strings.Contains(ref, "\n") || strings.HasPrefix(ref, "github.com/") {
+			continue
+		}
+
+		target := path.Join(dir, ref)
+		if _, err := tree.File(path.Join(target, "kustomization.yaml")); err == nil {
+			overlayInputs(tree, target, inputs)
+			continue
+		}
+		inputs[target] = true
+	}
+}
+
+// generatorFiles are the files a generator reads, a file source may be named, i.e key=path.
+func generatorFiles(args types.GeneratorArgs) []string {
+	files := append([]string{}, args.EnvSources...)
+	for _, source := range args.FileSources {
+		if i := strings.Index(source, "="); i >= 0 {
+			source = source[i+1:]
+		}
+		files = append(files, source)
+	}
+
+	return files
+}
+
+// touches reports whether any changed path is one of the inputs, or within one of their directories.
+func touches(inputs map[string]bool, changed []string) bool {
+	for _, name := range changed {
+		if inputs[name] {
+			return true
+		}
+
+		for input := range inputs {
+			if strings.HasSuffix(input, "/") && strings.HasPrefix(name, input) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RenderOverlays builds each of the overlays in the commit in memory, returning the images of its
+// workloads keyed by the overlay's kustomization.yaml. Overlays missing from the commit are left out,
+// those that fail to build are returned in failed. An image is listed once for each workload
+// running it, so a change to any of them is found.
+func RenderOverlays(commit *object.Commit, overlays []string) (rendered map[string]*types.Kustomization, failed map[string]error, err error) {
+	fs, err := treeToFS(commit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+
+	rendered = map[string]*types.Kustomization{}
+	failed = map[string]error{}
+	for _, overlay := range overlays {
+		if !fs.Exists(overlay) {
+			continue
+		}
+
+		resources, err := k.Run(fs, path.Dir(overlay))
+		if err != nil {
+			failed[overlay] = fmt.Errorf("failed to build %s: %w", path.Dir(overlay), err)
+			continue
+		}
+
+		images := []types.Image{}
+		for _, res := range resources.Resources() {
+			specPath, ok := workloadContainers[res.GetKind()]
+			if !ok {
+				continue
+			}
+
+			obj, err := res.Map()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read %s %s in %s: %w", res.GetKind(), res.GetName(), overlay, err)
+			}
+
+			seen := map[types.Image]bool{}
+			for _, image := range podImages(obj, specPath) {
+				if seen[image] {
+					continue
+				}
+
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+
+		rendered[overlay] = &types.Kustomization{Images: images}
+	}
+
+	return rendered, failed, nil
+}
+
+// treeToFS copies the commit's files into an in memory filesystem for kustomize.
+func treeToFS(commit *object.Commit) (filesys.FileSystem, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree for %s: %w", commit.Hash, err)
+	}
+
+	fs := filesys.MakeFsInMemory()
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !f.Mode.IsFile() {
+			return nil
+		}
+
+		reader, err := f.Reader()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+
+		if err := fs.WriteFile(f.Name, data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Name, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// leafOverlays returns the kustomizations under environments/ that have no other kustomization
+// in a subdirectory, i.e the namespaces rather than the environment including them.
+func leafOverlays(kustomizations []string) []string {
+	overlays := []string{}
+	for _, candidate := range kustomizations {
+		if environment, _ := OverlayFor(candidate); environment == "" {
+			continue
+		}
+
+		dir := path.Dir(candidate) + "/"
+		leaf := true
+		for _, other := range kustomizations {
+			if other != candidate && strings.HasPrefix(other, dir) {
+				leaf = false
+				break
+			}
+		}
+
+		if leaf {
+			overlays = append(overlays, candidate)
+		}
+	}
+
+	sort.Strings(overlays)
+
+	return overlays
+}
+
+// podImages returns the images of the containers and init containers in the pod spec at specPath.
+func podImages(obj map[string]interface{}, specPath []string) []types.Image {
+	var spec interface{} = obj
+	for _, field := range specPath {
+		m, ok := spec.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		spec = m[field]
+	}
+
+	podSpec, ok := spec.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	images := []types.Image{}
+	for _, key := range []string{"initContainers", "containers"} {
+		containers, _ := podSpec[key].([]interface{})
+		for _, c := range containers {
+			container, _ := c.(map[string]interface{})
+			image, _ := container["image"].(string)
+			if image == "" {
+				continue
+			}
+
+			images = append(images, splitImage(image))
+		}
+	}
+
+	return images
+}
+
+// splitImage splits a container image into its name, tag and digest,
+// i.e registry:5000/adarga/some-service:1.2.3@sha256:abc
+func splitImage(image string) types.Image {
+	result := types.Image{Name: image}
+	if i := strings.Index(result.Name, "@"); i >= 0 {
+		result.Digest = result.Name[i+1:]
+		result.Name = result.Name[:i]
+	}
+
+	// a : before the last / is a registry port rather than a tag
+	if i := strings.LastIndex(result.Name, ":"); i > strings.LastIndex(result.Name, "/") {
+		result.NewTag = result.Name[i+1:]
+		result.Name = result.Name[:i]
+	}
+
+	return result
+}
@@ -0,0 +1,142 @@
+package git_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/git"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: some-service
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: registry:5000/adarga/migrations:0.1.0
+      containers:
+      - name: some-service
+        image: adarga/some-service:%s
+`
+
+const cronJob = `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: cleanup
+            image: adarga/cleanup:1.0.0
+`
+
+func TestRenderImagesFromK8s(t *testing.T) {
+	const overlay = "environments/engine-prod/baseline/wb-lfqa/kustomization.yaml"
+
+	dir := t.TempDir()
+	r, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	source := commitFiles(t, r, dir, map[string]string{
+		"base/some-service/kustomization.yaml": "resources:\n- deployment.yaml\n- cronjob.yaml\n",
+		"base/some-service/deployment.yaml":    fmt.Sprintf(deployment, "1.0.0"),
+		"base/some-service/cronjob.yaml":       cronJob,
+		// the environment includes its namespaces, only the namespaces are built
+		"environments/engine-prod/baseline/kustomization.yaml": "resources:\n- wb-lfqa\n",
+		overlay: "resources:\n- ../../../../base/some-service\nimages:\n- name: adarga/cleanup\n  newTag: 2.0.0\n",
+		// can't be built, but isn't affected so it's never rendered
+		"environments/engine-prod/baseline/wb-remote/kustomization.yaml": "resources:\n- https://github.com/Adarga-Ltd/platform//base?ref=v1\n",
+		// can't be built and is affected, it's skipped rather than failing the rest
+		"environments/engine-prod/baseline/wb-broken/kustomization.yaml": "resources:\n- missing.yaml\n",
+		"environments/engine-prod/baseline/wb-broken/values.yaml":        "replicas: 1\n",
+	})
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("bump-base"), Create: true}))
+
+	// only the base changes, the overlay's images: stanza is untouched
+	commitFiles(t, r, dir, map[string]string{
+		"base/some-service/deployment.yaml":                       fmt.Sprintf(deployment, "1.1.0"),
+		"environments/engine-prod/baseline/wb-broken/values.yaml": "replicas: 2\n",
+	})
+
+	commit, err := git.ResolveCommit(r, "bump-base")
+	require.NoError(t, err)
+	sourceCommit, err := git.ResolveCommit(r, source.String())
+	require.NoError(t, err)
+
+	affected, err := git.AffectedOverlays(sourceCommit, commit)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"environments/engine-prod/baseline/wb-broken/kustomization.yaml", overlay}, affected)
+
+	diffs, err := git.RenderImagesFromK8s(zap.NewNop(), r, source.String(), "bump-base")
+	require.NoError(t, err)
+	assert.Equal(t, []git.ImageDiff{
+		{Path: overlay, Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/some-service", Kind: git.ImageRetagged, Tag1: "1.0.0", Tag2: "1.1.0"},
+	}, diffs)
+
+	// the stanza alone misses the change
+	diffs, err = git.GetImagesFromK8s(r, source.String(), "bump-base")
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	overlays, failed, err := git.RenderOverlays(commit, affected)
+	require.NoError(t, err)
+	require.Contains(t, overlays, overlay)
+	assert.Len(t, overlays, 1)
+	assert.Contains(t, failed, "environments/engine-prod/baseline/wb-broken/kustomization.yaml")
+	assert.Equal(t, []types.Image{
+		{Name: "registry:5000/adarga/migrations", NewTag: "0.1.0"},
+		{Name: "adarga/some-service", NewTag: "1.1.0"},
+		{Name: "adarga/cleanup", NewTag: "2.0.0"},
+	}, overlays[overlay].Images)
+}
+
+func TestRenderImagesSharedByWorkloads(t *testing.T) {
+	const overlay = "environments/engine-prod/baseline/wb-lfqa/kustomization.yaml"
+	const canary = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: some-service-canary
+spec:
+  template:
+    spec:
+      containers:
+      - name: some-service
+        image: adarga/some-service:%s
+`
+
+	dir := t.TempDir()
+	r, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	source := commitFiles(t, r, dir, map[string]string{
+		"environments/engine-prod/baseline/wb-lfqa/kustomization.yaml": "resources:\n- deployment.yaml\n- canary.yaml\n",
+		"environments/engine-prod/baseline/wb-lfqa/deployment.yaml":    fmt.Sprintf(deployment, "1.0.0"),
+		"environments/engine-prod/baseline/wb-lfqa/canary.yaml":        fmt.Sprintf(canary, "1.0.0"),
+	})
+
+	// only the second workload using the image changes
+	target := commitFiles(t, r, dir, map[string]string{
+		"environments/engine-prod/baseline/wb-lfqa/canary.yaml": fmt.Sprintf(canary, "1.2.0"),
+	})
+
+	diffs, err := git.RenderImagesFromK8s(zap.NewNop(), r, source.String(), target.String())
+	require.NoError(t, err)
+	assert.Equal(t, []git.ImageDiff{
+		{Path: overlay, Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/some-service", Kind: git.ImageRetagged, Tag1: "1.0.0", Tag2: "1.2.0"},
+	}, diffs)
+}
@@ -8,11 +8,29 @@ import (
 
 	"os"
 
+	"github.com/alex-emery/release-notes/pkg/config"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"go.uber.org/zap"
 )
 
-func New(logger *zap.Logger, override string) (*Auth, error) {
+func New(logger *zap.Logger, cfg *config.Config, override string) (*Auth, error) {
+	auth, err := newAuth(logger, cfg, override)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Cache.Enabled {
+		if auth.cache, err = CacheFromConfig(cfg); err != nil {
+			return nil, err
+		}
+
+		logger.Debug("caching repos", zap.String("path", auth.cache.Dir))
+	}
+
+	return auth, nil
+}
+
+func newAuth(logger *zap.Logger, cfg *config.Config, override string) (*Auth, error) {
 	if override != "" {
 		publicKey, err := ssh.NewPublicKeysFromFile("git", override, "")
 		if err != nil {
@@ -22,8 +40,9 @@ func New(logger *zap.Logger, override string) (*Auth, error) {
 		logger.Debug("using private key", zap.String("path", override))
 		return &Auth{
 			logger: logger,
+			config: cfg,
 			Keys:   publicKey,
-			Path:   "git@github.com:Adarga-Ltd/",
+			Path:   cfg.CloneURLPrefix(),
 		}, nil
 	}
 
@@ -47,8 +66,9 @@ func New(logger *zap.Logger, override string) (*Auth, error) {
 		logger.Debug("using private key", zap.String("path", keyPath))
 		return &Auth{
 			logger: logger,
+			config: cfg,
 			Keys:   publicKey,
-			Path:   "git@github.com:Adarga-Ltd/",
+			Path:   cfg.CloneURLPrefix(),
 		}, nil
 	}
 
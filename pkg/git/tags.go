@@ -0,0 +1,341 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// TagScheme resolves image tags to the commits they were built from.
+type TagScheme interface {
+	// Resolve returns the commit, or tag, the image tag was built from.
+	Resolve(r *git.Repository, tag string) (plumbing.Hash, error)
+	// Compare orders two image tags, ok is false when the scheme has no order, i.e SHAs,
+	// or either tag doesn't follow the scheme.
+	Compare(tag1, tag2 string) (result int, ok bool)
+}
+
+// NewTagScheme creates the scheme for the configured strategy.
+func NewTagScheme(tags config.Tags) (TagScheme, error) {
+	switch tags.Strategy {
+	case config.TagStrategySemver:
+		return semverScheme{prefixes: tags.Prefixes}, nil
+	case config.TagStrategyCalver:
+		return calverScheme{prefixes: tags.Prefixes}, nil
+	case config.TagStrategySHA:
+		return shaScheme{}, nil
+	case config.TagStrategyRegex:
+		re, err := regexp.Compile(tags.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile tag pattern %s: %w", tags.Pattern, err)
+		}
+
+		return regexScheme{re: re}, nil
+	}
+
+	return nil, fmt.Errorf("unknown tag strategy %q", tags.Strategy)
+}
+
+// stripPrefix removes the first matching prefix and then any leading v, i.e some-service/v1.2.3 becomes 1.2.3
+func stripPrefix(prefixes []string, tag string) string {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(tag, prefix) {
+			tag = strings.TrimPrefix(tag, prefix)
+			break
+		}
+	}
+
+	return strings.TrimPrefix(tag, "v")
+}
+
+// findTag returns the first git tag for which match returns true.
+func findTag(r *git.Repository, match func(name string) bool) (plumbing.Hash, error) {
+	iter, err := r.Tags()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	found := plumbing.ZeroHash
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if found.IsZero() && match(ref.Name().Short()) {
+			found = ref.Hash()
+		}
+
+		return nil
+	})
+
+	return found, err
+}
+
+type semverScheme struct {
+	prefixes []string
+}
+
+func (s semverScheme) Resolve(r *git.Repository, tag string) (plumbing.Hash, error) {
+	want, err := semver.NewVersion(stripPrefix(s.prefixes, tag))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	hash, err := findTag(r, func(name string) bool {
+		v, err := semver.NewVersion(stripPrefix(s.prefixes, name))
+		return err == nil && v.Equal(want)
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if hash.IsZero() {
+		return plumbing.ZeroHash, fmt.Errorf("no git tag for version %s", want)
+	}
+
+	return hash, nil
+}
+
+func (s semverScheme) Compare(tag1, tag2 string) (int, bool) {
+	v1, err := semver.NewVersion(stripPrefix(s.prefixes, tag1))
+	if err != nil {
+		return 0, false
+	}
+
+	v2, err := semver.NewVersion(stripPrefix(s.prefixes, tag2))
+	if err != nil {
+		return 0, false
+	}
+
+	return v1.Compare(v2), true
+}
+
+// calendar versions are digits split by ., - or _ starting with the year or full date, i.e 2023.10.01, 2023.10.1-2 or 20231001
+var calverPattern = regexp.MustCompile(`^(\d{4}|\d{8})([._-]\d+)*$`)
+
+type calverScheme struct {
+	prefixes []string
+}
+
+// parseCalver splits a calendar version into its numbers, so 2023.10.01 and 2023.10.1 are equal.
+func parseCalver(tag string) ([]int, error) {
+	if !calverPattern.MatchString(tag) {
+		return nil, fmt.Errorf("%s is not a calendar version", tag)
+	}
+
+	parts := strings.FieldsFunc(tag, func(r rune) bool {
+		return r == '.' || r == '-' || r == '_'
+	})
+
+	version := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		version = append(version, n)
+	}
+
+	return version, nil
+}
+
+func compareCalver(v1, v2 []int) int {
+	for i := 0; i < len(v1) && i < len(v2); i++ {
+		if v1[i] != v2[i] {
+			if v1[i] < v2[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case len(v1) < len(v2):
+		return -1
+	case len(v1) > len(v2):
+		return 1
+	}
+
+	return 0
+}
+
+func (s calverScheme) Resolve(r *git.Repository, tag string) (plumbing.Hash, error) {
+	want, err := parseCalver(stripPrefix(s.prefixes, tag))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	hash, err := findTag(r, func(name string) bool {
+		v, err := parseCalver(stripPrefix(s.prefixes, name))
+		return err == nil && compareCalver(v, want) == 0
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if hash.IsZero() {
+		return plumbing.ZeroHash, fmt.Errorf("no git tag for version %s", tag)
+	}
+
+	return hash, nil
+}
+
+func (s calverScheme) Compare(tag1, tag2 string) (int, bool) {
+	v1, err := parseCalver(stripPrefix(s.prefixes, tag1))
+	if err != nil {
+		return 0, false
+	}
+
+	v2, err := parseCalver(stripPrefix(s.prefixes, tag2))
+	if err != nil {
+		return 0, false
+	}
+
+	return compareCalver(v1, v2), true
+}
+
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+type shaScheme struct{}
+
+func (shaScheme) Resolve(r *git.Repository, tag string) (plumbing.Hash, error) {
+	if !shaPattern.MatchString(tag) {
+		return plumbing.ZeroHash, fmt.Errorf("%s is not a commit SHA", tag)
+	}
+
+	return resolveCommit(r, tag)
+}
+
+func (shaScheme) Compare(string, string) (int, bool) {
+	return 0, false
+}
+
+type regexScheme struct {
+	re *regexp.Regexp
+}
+
+// Resolve tries the captured value as a git tag, then as a commit SHA.
+func (s regexScheme) Resolve(r *git.Repository, tag string) (plumbing.Hash, error) {
+	match := s.re.FindStringSubmatch(tag)
+	if len(match) < 2 || match[1] == "" {
+		return plumbing.ZeroHash, fmt.Errorf("%s does not match %s", tag, s.re)
+	}
+
+	ref, err := r.Tag(match[1])
+	if err == nil {
+		return ref.Hash(), nil
+	}
+
+	if !errors.Is(err, git.ErrTagNotFound) {
+		return plumbing.ZeroHash, err
+	}
+
+	return resolveCommit(r, match[1])
+}
+
+func (regexScheme) Compare(string, string) (int, bool) {
+	return 0, false
+}
+
+// resolveCommit resolves a full or abbreviated SHA to a commit.
+func resolveCommit(r *git.Repository, sha string) (plumbing.Hash, error) {
+	hash, err := r.ResolveRevision(plumbing.Revision(sha))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to find commit %s: %w", sha, err)
+	}
+
+	return *hash, nil
+}
+
+// any run of hex long enough to be a SHA, i.e 3eb443b in main-3eb443b or 1.2.3-4-g3eb443b
+var embeddedSHAPattern = regexp.MustCompile(`[0-9a-f]{7,40}`)
+
+// ResolveTag finds the commit an image tag was built from using the scheme,
+// falling back to a commit SHA within the tag when the scheme can't resolve it.
+func ResolveTag(r *git.Repository, scheme TagScheme, tag string) (plumbing.Hash, error) {
+	hash, err := scheme.Resolve(r, tag)
+	if err == nil {
+		return hash, nil
+	}
+
+	// a date stamp can look like a SHA, so only candidates that resolve to a commit are used
+	for _, candidate := range embeddedSHAPattern.FindAllString(tag, -1) {
+		if hash, shaErr := resolveCommit(r, candidate); shaErr == nil {
+			return hash, nil
+		}
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("failed to resolve tag %s and it contains no commit SHA: %w", tag, err)
+}
+
+// TagInfo is a semver git tag and the commit it points at.
+type TagInfo struct {
+	// Name is the git tag, i.e deploy-1.2.3
+	Name string
+	// Version is the tag without its prefix or v, i.e 1.2.3
+	Version string
+	Subject string
+	Date    time.Time
+}
+
+// SemverTags returns every git tag that's a semantic version once the prefixes are removed, newest first.
+func SemverTags(r *git.Repository, prefixes []string) ([]TagInfo, error) {
+	iter, err := r.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	type versionedTag struct {
+		info    TagInfo
+		version *semver.Version
+	}
+
+	tags := []versionedTag{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		version := stripPrefix(prefixes, ref.Name().Short())
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			return nil
+		}
+
+		commit, err := peelToCommit(r, ref.Hash())
+		if err != nil {
+			return err
+		}
+
+		tags = append(tags, versionedTag{
+			info: TagInfo{
+				Name:    ref.Name().Short(),
+				Version: version,
+				Subject: strings.Split(commit.Message, "\n")[0],
+				Date:    commit.Committer.When,
+			},
+			version: v,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		if !tags[i].version.Equal(tags[j].version) {
+			return tags[i].version.GreaterThan(tags[j].version)
+		}
+
+		return tags[i].info.Name < tags[j].info.Name
+	})
+
+	result := make([]TagInfo, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, tag.info)
+	}
+
+	return result, nil
+}
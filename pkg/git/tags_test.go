@@ -0,0 +1,149 @@
+package git_test
+
+import (
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tagsRepo creates a commit for each set of tags, returning the repo and the commit SHAs, oldest first.
+func tagsRepo(t *testing.T, tags ...[]string) (*gogit.Repository, []string) {
+	t.Helper()
+
+	r, err := gogit.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+
+	shas := []string{}
+	for i, names := range tags {
+		commitAndTag(t, r, "commit", names[0])
+		head, err := r.Head()
+		require.NoError(t, err)
+
+		for _, name := range names[1:] {
+			_, err = r.CreateTag(name, head.Hash(), nil)
+			require.NoError(t, err, "tag %d", i)
+		}
+
+		shas = append(shas, head.Hash().String())
+	}
+
+	return r, shas
+}
+
+func TestCommitsBetweenTags(t *testing.T) {
+	r, shas := tagsRepo(t,
+		[]string{"some-service/v1.0.0", "2023.09.28", "build-1"},
+		[]string{"some-service/v1.1.0", "2023.10.1", "build-2"},
+		[]string{"other-service/v1.2.0", "2023.10.12", "build-3"},
+	)
+
+	testCases := []struct {
+		name       string
+		tags       config.Tags
+		tag1, tag2 string
+		expected   []string
+	}{
+		{
+			name: "semver with a prefix",
+			tags: config.Tags{Strategy: config.TagStrategySemver, Prefixes: []string{"some-service/"}},
+			tag1: "1.0.0", tag2: "v1.1.0",
+			expected: []string{shas[1]},
+		},
+		{
+			name: "calver",
+			tags: config.Tags{Strategy: config.TagStrategyCalver},
+			tag1: "2023.09.28", tag2: "2023.10.12",
+			expected: []string{shas[2], shas[1]},
+		},
+		{
+			name: "sha",
+			tags: config.Tags{Strategy: config.TagStrategySHA},
+			tag1: shas[0][:7], tag2: shas[2],
+			expected: []string{shas[2], shas[1]},
+		},
+		{
+			name: "regex capturing a git tag",
+			tags: config.Tags{Strategy: config.TagStrategyRegex, Pattern: `^ci-(build-\d+)$`},
+			tag1: "ci-build-2", tag2: "ci-build-3",
+			expected: []string{shas[2]},
+		},
+		{
+			name: "regex capturing a sha",
+			tags: config.Tags{Strategy: config.TagStrategyRegex, Pattern: `^main-([0-9a-f]+)$`},
+			tag1: "main-" + shas[0][:8], tag2: "main-" + shas[1][:8],
+			expected: []string{shas[1]},
+		},
+		{
+			name: "falls back to a sha in the image tag",
+			tags: config.Tags{Strategy: config.TagStrategySemver},
+			tag1: "main-" + shas[0][:7], tag2: "1.3.0-rc.1-g" + shas[2][:10],
+			expected: []string{shas[2], shas[1]},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme, err := git.NewTagScheme(tc.tags)
+			require.NoError(t, err)
+
+			commits, err := git.CommitsBetweenTags(r, scheme, tc.tag1, tc.tag2)
+			require.NoError(t, err)
+
+			actual := []string{}
+			for _, commit := range commits {
+				actual = append(actual, commit.Hash.String())
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestCommitsBetweenTagsErrors(t *testing.T) {
+	r, shas := tagsRepo(t, []string{"v1.0.0"}, []string{"v1.1.0"})
+
+	scheme, err := git.NewTagScheme(config.Tags{Strategy: config.TagStrategySemver})
+	require.NoError(t, err)
+
+	_, err = git.CommitsBetweenTags(r, scheme, "1.1.0", "1.1.0")
+	assert.ErrorContains(t, err, "must be less than")
+
+	_, err = git.CommitsBetweenTags(r, scheme, "1.1.0", "1.0.0")
+	assert.ErrorIs(t, err, git.ErrRollback)
+
+	// SHAs have no order, a rollback is found through the history instead
+	shaScheme, err := git.NewTagScheme(config.Tags{Strategy: config.TagStrategySHA})
+	require.NoError(t, err)
+	_, err = git.CommitsBetweenTags(r, shaScheme, shas[1], shas[0])
+	assert.ErrorIs(t, err, git.ErrRollback)
+
+	_, err = git.CommitsBetweenTags(r, scheme, "1.0.0", "main-zzzzzzz")
+	assert.ErrorContains(t, err, "contains no commit SHA")
+}
+
+func TestSemverTags(t *testing.T) {
+	r, _ := tagsRepo(t,
+		[]string{"deploy-1.0.0"},
+		[]string{"deploy-1.10.0", "latest"},
+		[]string{"deploy-1.2.0", "v1.2.0"},
+	)
+
+	tags, err := git.SemverTags(r, []string{"deploy-"})
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+
+	// ordered by version rather than name, latest isn't a version
+	assert.Equal(t, []string{"deploy-1.10.0", "deploy-1.2.0", "v1.2.0", "deploy-1.0.0"}, names)
+	assert.Equal(t, "1.10.0", tags[0].Version)
+	assert.Equal(t, "commit", tags[0].Subject)
+	assert.False(t, tags[0].Date.IsZero())
+}
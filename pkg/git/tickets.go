@@ -0,0 +1,132 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// TicketRules is the set of patterns used to find tickets in commits,
+// i.e APP-\d+, PLAT-\d+ and DATA-\d+. A pattern with a capture group only
+// extracts the first group, so it can match the text around the ticket.
+type TicketRules []*regexp.Regexp
+
+func NewTicketRules(patterns []string) (TicketRules, error) {
+	rules := make(TicketRules, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile ticket pattern %s: %w", pattern, err)
+		}
+		rules = append(rules, re)
+	}
+
+	return rules, nil
+}
+
+// Find returns every unique ticket in text, in the order they first appear.
+func (r TicketRules) Find(text string) []string {
+	type match struct {
+		ticket string
+		index  int
+	}
+
+	matches := []match{}
+	for _, re := range r {
+		group := 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+
+		for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2*group], loc[2*group+1]
+			if start < 0 {
+				continue
+			}
+			matches = append(matches, match{ticket: text[start:end], index: start})
+		}
+	}
+
+	// order by position so a commit mentioning PLAT-1 then APP-2 keeps that order
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].index < matches[j].index
+	})
+
+	seen := map[string]bool{}
+	tickets := []string{}
+	for _, m := range matches {
+		if seen[m.ticket] {
+			continue
+		}
+		seen[m.ticket] = true
+		tickets = append(tickets, m.ticket)
+	}
+
+	return tickets
+}
+
+// FromCommit returns every ticket mentioned in the commit's subject, body and trailers
+// (i.e Refs: APP-123, Closes: APP-456) and, for merge commits, the merged branch name.
+func (r TicketRules) FromCommit(commit object.Commit) []string {
+	tickets := r.Find(commit.Message)
+
+	if branch := MergedBranch(commit.Message); branch != "" {
+		// branches are usually lower case, i.e app-123-fix-thing
+		for _, ticket := range r.Find(strings.ToUpper(branch)) {
+			if !contains(tickets, ticket) {
+				tickets = append(tickets, ticket)
+			}
+		}
+	}
+
+	return tickets
+}
+
+var mergeMessagePatterns = []*regexp.Regexp{
+	// GitHub: Merge pull request #12 from org/branch
+	regexp.MustCompile(`^Merge pull request #\d+ from [^/\s]+/(\S+)`),
+	// git: Merge branch 'branch' into main, Merge remote-tracking branch 'origin/branch'
+	regexp.MustCompile(`^Merge (?:remote-tracking )?branch '([^']+)'`),
+}
+
+// MergedBranch returns the name of the branch merged by a merge commit, based on its subject.
+// Returns an empty string when the message isn't a merge.
+func MergedBranch(message string) string {
+	subject := strings.Split(message, "\n")[0]
+	for _, re := range mergeMessagePatterns {
+		if match := re.FindStringSubmatch(subject); len(match) > 1 {
+			return match[1]
+		}
+	}
+
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bundles commits to the respective ticket
+type IssueCommitMap map[string][]object.Commit
+
+// Gets all unique tickets from a list of commits.
+// A commit mentioning several tickets is added to each of them.
+func CommitsToIssues(rules TicketRules, commits []object.Commit) IssueCommitMap {
+	commitMap := make(IssueCommitMap)
+	for _, commit := range commits {
+		for _, ticket := range rules.FromCommit(commit) {
+			commitMap[ticket] = append(commitMap[ticket], commit)
+		}
+	}
+
+	return commitMap
+}
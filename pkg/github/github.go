@@ -3,27 +3,32 @@ package github
 import (
 	"context"
 	"fmt"
+	"net/http"
 
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/httpretry"
 	"github.com/google/go-github/v56/github"
 	"go.uber.org/zap"
 )
 
 type Client struct {
 	client *github.Client
+	config *config.Config
 	logger *zap.Logger
 }
 
-func New(logger *zap.Logger, token string) *Client {
+func New(logger *zap.Logger, cfg *config.Config, token string) *Client {
 	return &Client{
 		logger: logger,
-		client: github.NewClient(nil).WithAuthToken(token),
+		config: cfg,
+		client: github.NewClient(&http.Client{Transport: httpretry.NewTransport(logger, nil)}).WithAuthToken(token),
 	}
 }
 
 func (c *Client) CreatePR(ctx context.Context, head, base, title, body string) error {
 	c.logger.Debug("creating PR", zap.String("head", head), zap.String("base", base), zap.String("title", title), zap.String("body", body))
 	// put the PR in the current template.
-	resp, _, err := c.client.PullRequests.Create(ctx, "Adarga-Ltd", "k8s-engine", &github.NewPullRequest{
+	resp, _, err := c.client.PullRequests.Create(ctx, c.config.GitHub.Org, c.config.K8sEngine.Repo, &github.NewPullRequest{
 		Title: github.String(title),
 		Body:  github.String(body),
 		Head:  github.String(head),
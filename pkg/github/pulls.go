@@ -0,0 +1,193 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v56/github"
+	"go.uber.org/zap"
+)
+
+// PullRequest is a merged pull request a commit was part of.
+type PullRequest struct {
+	Number int
+	Title  string
+	Author string
+	Labels []string
+	// MergedAt is in UTC.
+	MergedAt time.Time
+	// Reviewers are the users who left a review, sorted by login.
+	Reviewers []string
+	URL       string
+}
+
+// maxConcurrentLookups bounds the requests in flight, GitHub's secondary rate limits punish bursts.
+const maxConcurrentLookups = 4
+
+// PullRequestsForCommits maps each sha to the merged pull requests it was part of, sorted by number.
+// GitHub associates squash, rebase and merge commits with their PR, so none rely on the commit subject.
+// Commits without a merged PR are left out of the map, as are commits whose PRs couldn't be listed,
+// an error is only returned when none of them could be.
+func (c *Client) PullRequestsForCommits(ctx context.Context, repo string, shas []string) (map[string][]PullRequest, error) {
+	merged := make([][]*github.PullRequest, len(shas))
+	errs := make([]error, len(shas))
+	forEach(len(shas), func(i int) {
+		prs, err := c.mergedPullRequests(ctx, repo, shas[i])
+		if err != nil {
+			c.logger.Warn("failed to list pull requests for commit, skipping", zap.String("repo", repo), zap.String("sha", shas[i]), zap.Error(err))
+			errs[i] = err
+			return
+		}
+		merged[i] = prs
+	})
+
+	if len(shas) > 0 && allFailed(errs) {
+		return nil, fmt.Errorf("failed to list pull requests for every commit in %s: %w", repo, errs[0])
+	}
+
+	// a PR is usually shared by several commits, only look up its reviews once
+	unique := []*github.PullRequest{}
+	index := map[int]int{}
+	for _, prs := range merged {
+		for _, pr := range prs {
+			if _, ok := index[pr.GetNumber()]; !ok {
+				index[pr.GetNumber()] = len(unique)
+				unique = append(unique, pr)
+			}
+		}
+	}
+
+	pullRequests := make([]PullRequest, len(unique))
+	forEach(len(unique), func(i int) {
+		pullRequests[i] = c.newPullRequest(ctx, repo, unique[i])
+	})
+
+	result := map[string][]PullRequest{}
+	for i, sha := range shas {
+		for _, pr := range merged[i] {
+			result[sha] = append(result[sha], pullRequests[index[pr.GetNumber()]])
+		}
+
+		sort.Slice(result[sha], func(i, j int) bool {
+			return result[sha][i].Number < result[sha][j].Number
+		})
+	}
+
+	return result, nil
+}
+
+// mergedPullRequests lists the merged PRs containing the commit.
+func (c *Client) mergedPullRequests(ctx context.Context, repo, sha string) ([]*github.PullRequest, error) {
+	c.logger.Debug("listing pull requests for commit", zap.String("repo", repo), zap.String("sha", sha))
+
+	merged := []*github.PullRequest{}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		prs, resp, err := c.client.PullRequests.ListPullRequestsWithCommit(ctx, c.config.GitHub.Org, repo, sha, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pull requests for %s in %s: %w", sha, repo, err)
+		}
+
+		for _, pr := range prs {
+			// open PRs can contain commits that have since been merged elsewhere
+			if pr.MergedAt != nil {
+				merged = append(merged, pr)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return merged, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// allFailed reports whether every lookup returned an error.
+func allFailed(errs []error) bool {
+	for _, err := range errs {
+		if err == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// forEach calls fn for every index up to n, at most maxConcurrentLookups at a time.
+func forEach(n int, fn func(i int)) {
+	sem := make(chan struct{}, maxConcurrentLookups)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+// newPullRequest converts the PR, its reviewers are left empty when they can't be listed.
+func (c *Client) newPullRequest(ctx context.Context, repo string, pr *github.PullRequest) PullRequest {
+	labels := make([]string, 0, len(pr.Labels))
+	for _, label := range pr.Labels {
+		labels = append(labels, label.GetName())
+	}
+
+	author := pr.GetUser().GetLogin()
+	reviewers, err := c.reviewers(ctx, repo, pr.GetNumber(), author)
+	if err != nil {
+		c.logger.Warn("failed to list reviewers, leaving them out", zap.String("repo", repo), zap.Int("number", pr.GetNumber()), zap.Error(err))
+		reviewers = []string{}
+	}
+
+	return PullRequest{
+		Number:    pr.GetNumber(),
+		Title:     pr.GetTitle(),
+		Author:    author,
+		Labels:    labels,
+		MergedAt:  pr.GetMergedAt().UTC(),
+		Reviewers: reviewers,
+		URL:       pr.GetHTMLURL(),
+	}
+}
+
+// reviewers returns the unique logins that reviewed the PR, leaving out the author replying to comments.
+func (c *Client) reviewers(ctx context.Context, repo string, number int, author string) ([]string, error) {
+	seen := map[string]bool{}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := c.client.PullRequests.ListReviews(ctx, c.config.GitHub.Org, repo, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list reviews for %s#%d: %w", repo, number, err)
+		}
+
+		for _, review := range reviews {
+			seen[review.GetUser().GetLogin()] = true
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	reviewers := make([]string, 0, len(seen))
+	for login := range seen {
+		if login != "" && login != author {
+			reviewers = append(reviewers, login)
+		}
+	}
+	sort.Strings(reviewers)
+
+	return reviewers, nil
+}
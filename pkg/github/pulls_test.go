@@ -0,0 +1,85 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/google/go-github/v56/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPullRequestsForCommits(t *testing.T) {
+	reviewCalls := 0
+	mux := http.NewServeMux()
+	// a squash merge and a rebase merged commit from the same PR, plus an unmerged PR
+	mux.HandleFunc("/repos/Adarga-Ltd/some-service/commits/aaa/pulls", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"number": 12, "title": "Support rating", "html_url": "https://github.com/Adarga-Ltd/some-service/pull/12",
+			"user": {"login": "dev"}, "labels": [{"name": "feature"}], "merged_at": "2023-10-01T12:00:00Z"}]`)
+	})
+	mux.HandleFunc("/repos/Adarga-Ltd/some-service/commits/bbb/pulls", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"number": 13, "title": "Draft", "user": {"login": "dev"}},
+			{"number": 12, "title": "Support rating", "html_url": "https://github.com/Adarga-Ltd/some-service/pull/12",
+			"user": {"login": "dev"}, "labels": [{"name": "feature"}], "merged_at": "2023-10-01T12:00:00Z"}]`)
+	})
+	mux.HandleFunc("/repos/Adarga-Ltd/some-service/commits/ccc/pulls", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	// a failing commit is skipped, the rest are still resolved
+	mux.HandleFunc("/repos/Adarga-Ltd/some-service/commits/ddd/pulls", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/repos/Adarga-Ltd/some-service/pulls/12/reviews", func(w http.ResponseWriter, r *http.Request) {
+		reviewCalls++
+		fmt.Fprint(w, `[{"user": {"login": "reviewer-b"}}, {"user": {"login": "dev"}}, {"user": {"login": "reviewer-a"}}, {"user": {"login": "reviewer-b"}}]`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New(zap.NewNop(), config.Default(), "token")
+	client.client = github.NewClient(nil)
+	client.client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	prs, err := client.PullRequestsForCommits(context.Background(), "some-service", []string{"aaa", "bbb", "ccc", "ddd"})
+	require.NoError(t, err)
+
+	expected := PullRequest{
+		Number:    12,
+		Title:     "Support rating",
+		Author:    "dev",
+		Labels:    []string{"feature"},
+		MergedAt:  time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC),
+		Reviewers: []string{"reviewer-a", "reviewer-b"},
+		URL:       "https://github.com/Adarga-Ltd/some-service/pull/12",
+	}
+
+	assert.Equal(t, map[string][]PullRequest{"aaa": {expected}, "bbb": {expected}}, prs)
+	assert.Equal(t, 1, reviewCalls)
+}
+
+func TestPullRequestsForCommitsAllFailing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := New(zap.NewNop(), config.Default(), "token")
+	client.client = github.NewClient(nil)
+	client.client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	_, err := client.PullRequestsForCommits(context.Background(), "some-service", []string{"aaa", "bbb"})
+	require.Error(t, err)
+
+	// no commits, nothing to fail
+	prs, err := client.PullRequestsForCommits(context.Background(), "some-service", nil)
+	require.NoError(t, err)
+	assert.Empty(t, prs)
+}
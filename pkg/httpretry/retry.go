@@ -0,0 +1,115 @@
+// Package httpretry retries HTTP requests that were rate limited.
+package httpretry
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxRetries = 5
+	defaultBaseDelay  = time.Second
+	maxDelay          = time.Minute
+)
+
+// retryTransport retries requests that were rate limited (429) or hit a busy server (503).
+// The Retry-After header is honoured when present, otherwise it backs off exponentially.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	baseDelay  time.Duration
+	logger     *zap.Logger
+}
+
+// NewTransport wraps next, http.DefaultTransport when nil, to retry rate limited requests.
+func NewTransport(logger *zap.Logger, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &retryTransport{
+		next:       next,
+		maxRetries: defaultMaxRetries,
+		baseDelay:  defaultBaseDelay,
+		logger:     logger,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attemptReq := req
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+			return resp, nil
+		}
+
+		if attempt >= t.maxRetries {
+			return resp, nil
+		}
+
+		// the caller's request mustn't be modified, each retry is a clone with a fresh body.
+		// requests with a body can only be retried if the body can be read again.
+		retryReq := req.Clone(req.Context())
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+			retryReq.Body = body
+		}
+
+		delay := t.delay(resp, attempt)
+		t.logger.Debug("request throttled, retrying",
+			zap.String("url", req.URL.String()),
+			zap.Int("status", resp.StatusCode),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay))
+
+		// drain the body so the connection can be reused.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+
+		attemptReq = retryReq
+	}
+}
+
+// delay uses Retry-After, either seconds or an HTTP date, falling back to exponential backoff.
+func (t *retryTransport) delay(resp *http.Response, attempt int) time.Duration {
+	delay := t.baseDelay << attempt
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			delay = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(retryAfter); err == nil {
+			delay = time.Until(date)
+		}
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay
+}
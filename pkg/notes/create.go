@@ -6,92 +6,299 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/alex-emery/release-notes/pkg/config"
 	"github.com/alex-emery/release-notes/pkg/git"
-	jira "github.com/andygrunwald/go-jira/v2/cloud"
+	"github.com/alex-emery/release-notes/pkg/tracker"
+	gogit "github.com/go-git/go-git/v5"
 	"go.uber.org/zap"
 )
 
-func CreateReleaseNotesFromK8sEngine(ctx context.Context, logger *zap.Logger, gitAuth *git.Auth, jiraClient *jira.Client, repoPath string, sourceBranch string, targetBranch *string) (string, error) {
+// Release is everything gathered from a k8s-engine diff.
+type Release struct {
+	Images []git.ImageDiff
+	Notes  []ReleaseNote
+}
+
+// NotesMarkdown renders the changes in each overlay and the release notes, without the env template.
+func (r Release) NotesMarkdown(logger *zap.Logger, cfg *config.Config) string {
+	return renderNotes(logger, cfg, r.Images, r.Notes...)
+}
+
+// Markdown renders the release notes wrapped in the env template, used as the PR body.
+func (r Release) Markdown(logger *zap.Logger, cfg *config.Config) (string, error) {
+	body := r.NotesMarkdown(logger, cfg)
+
+	release := NewReleaseTemplate(cfg, body, r.Notes...)
+	release.Deployments = newDeployments(cfg, r.Images)
+
+	return WrapReleaseWithEnvTemplate(cfg, release)
+}
+
+// OpenK8sEngine opens the local k8s-engine repo at repoPath, or clones it when repoPath is empty.
+func OpenK8sEngine(logger *zap.Logger, gitAuth *git.Auth, repoPath string) (*gogit.Repository, error) {
 	logger.Info("getting k8s-engine repo")
 	repo, err := gitAuth.GetK8sEngineRepo(repoPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to get k8s-engine repo: %w", err)
+		return nil, fmt.Errorf("failed to get k8s-engine repo: %w", err)
 	}
 
 	logger.Info("k8s-engine repo opened")
 
-	originalBranch, err := repo.Head()
+	// a fresh clone is already up to date, a local repo is fetched so origin/main isn't stale
+	if repoPath != "" {
+		logger.Info("fetching k8s-engine", zap.String("remote", git.DefaultRemote))
+		if err := gitAuth.Fetch(repo, git.DefaultRemote); err != nil {
+			logger.Warn("failed to fetch k8s-engine, using the refs as they are", zap.Error(err))
+		}
+	}
+
+	return repo, nil
+}
+
+// DefaultTarget is the current branch, used when no target branch is set. A detached HEAD is compared by SHA.
+func DefaultTarget(logger *zap.Logger, repo *gogit.Repository) (string, error) {
+	logger.Debug("target branch not set, getting head ref")
+
+	head, err := repo.Head()
 	if err != nil {
 		return "", fmt.Errorf("failed to get current branch: %w", err)
 	}
 
-	defer func() {
-		if err = git.Checkout(repo, originalBranch); err != nil {
-			logger.Error("failed to restore to original branch", zap.Error(err))
-		}
-	}()
+	target := head.Hash().String()
+	if head.Name().IsBranch() {
+		target = head.Name().Short()
+	}
+	logger.Info("defaulting target branch", zap.String("target branch", target))
 
-	if *targetBranch == "" {
-		logger.Debug("target branch not set, getting head ref")
+	return target, nil
+}
 
-		*targetBranch = originalBranch.Name().Short()
-		logger.Info("defaulting target branch", zap.String("target branch", *targetBranch))
+// CreateReleaseNotesFromK8sEngine creates the notes for every image changed between the branches, pulls may be nil.
+func CreateReleaseNotesFromK8sEngine(ctx context.Context, logger *zap.Logger, cfg *config.Config, gitAuth *git.Auth, trackers tracker.Trackers, pulls PullRequestResolver, repo *gogit.Repository, sourceBranch, targetBranch string) (Release, error) {
+	for _, branch := range []string{sourceBranch, targetBranch} {
+		warnIfBehind(logger, repo, branch)
 	}
 
-	sourceRefs := fmt.Sprintf("refs/heads/%s", sourceBranch)
-	targetRefs := fmt.Sprintf("refs/heads/%s", *targetBranch)
-
-	logger.Info("fetching image tags from k8s-engine")
-	diffs, err := git.GetImagesFromK8s(repo, sourceRefs, targetRefs)
+	logger.Info("fetching image tags from k8s-engine", zap.Bool("render", cfg.K8sEngine.Render))
+	var (
+		diffs []git.ImageDiff
+		err   error
+	)
+	if cfg.K8sEngine.Render {
+		diffs, err = git.RenderImagesFromK8s(logger, repo, sourceBranch, targetBranch)
+	} else {
+		diffs, err = git.GetImagesFromK8s(repo, sourceBranch, targetBranch)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to get images from k8s: %w", err)
+		return Release{}, fmt.Errorf("failed to get images from k8s: %w", err)
 	}
 
+	return CreateReleaseNotesForImages(ctx, logger, cfg, gitAuth, trackers, pulls, diffs), nil
+}
+
+// CreateReleaseNotesForImages creates the notes for the image diffs, an image deployed to several
+// namespaces only has its notes created once. pulls may be nil.
+func CreateReleaseNotesForImages(ctx context.Context, logger *zap.Logger, cfg *config.Config, gitAuth *git.Auth, trackers tracker.Trackers, pulls PullRequestResolver, diffs []git.ImageDiff) Release {
 	logger.Info("creating release notes")
 
-	resultChan := make(chan ReleaseNote, len(diffs))
+	changes := uniqueChanges(logger, cfg, diffs)
+
+	resultChan := make(chan repoCommits, len(changes))
 	wg := sync.WaitGroup{}
-	for _, diff := range diffs {
+	for _, change := range changes {
 		wg.Add(1)
-		go func(diff git.ImageDiff) {
+		go func(change imageChange) {
 			defer func() {
 				wg.Done()
 			}()
 
-			logger.Debug("diff", zap.String("name", diff.Name), zap.String("tag1", diff.Tag1), zap.String("tag2", diff.Tag2))
-			if repoName := git.ExtractRepoName(diff.Name); repoName != "" {
-				resultChan <- CreateReleaseNotesForRepo(ctx, logger, jiraClient, gitAuth, repoName, diff.Tag1, diff.Tag2)
-			} else {
-				resultChan <- ReleaseNote{}
+			repo, err := collectRepoCommits(logger, cfg, trackers, gitAuth, change.repoName, change.tag1, change.tag2)
+			if err != nil {
+				logger.Error("failed to get commits: skipping", zap.String("repo", change.repoName), zap.Error(err))
+				return
 			}
-		}(diff)
+
+			repo.image = change.image
+			lookupPullRequests(ctx, logger, pulls, &repo)
+			resultChan <- repo
+		}(change)
 	}
 
 	wg.Wait()
 	close(resultChan)
 
-	results := []ReleaseNote{}
-	for res := range resultChan {
-		if res.RepoName == "" {
+	repos := []repoCommits{}
+	for repo := range resultChan {
+		repos = append(repos, repo)
+	}
+
+	// issues are fetched once for the whole release, rather than per repo.
+	release := Release{
+		Images: diffs,
+		Notes:  resolveIssues(ctx, logger, cfg, trackers, repos...),
+	}
+	// results arrive in whatever order the goroutines finished
+	release.Sort(cfg)
+
+	return release
+}
+
+// warnIfBehind warns when a local branch is missing commits from its upstream, the notes would be stale.
+func warnIfBehind(logger *zap.Logger, repo *gogit.Repository, branch string) {
+	upstream, ok, err := git.UpstreamOf(repo, branch)
+	if err != nil {
+		logger.Warn("failed to compare branch with its upstream", zap.String("branch", branch), zap.Error(err))
+		return
+	}
+
+	if ok && upstream.Behind > 0 {
+		logger.Warn("local branch is behind its upstream, pull it or compare the upstream instead",
+			zap.String("branch", branch), zap.String("upstream", upstream.Name), zap.Int("behind", upstream.Behind))
+	}
+}
+
+// imageChange is a repo's tag range, shared by every overlay deploying the same change.
+type imageChange struct {
+	repoName string
+	image    string
+	tag1     string
+	tag2     string
+}
+
+// uniqueChanges maps the image diffs onto repo tag ranges, so the notes for an image
+// deployed to several namespaces are only created once.
+func uniqueChanges(logger *zap.Logger, cfg *config.Config, diffs []git.ImageDiff) []imageChange {
+	seen := map[imageChange]bool{}
+	changes := []imageChange{}
+	for _, diff := range diffs {
+		logger.Debug("diff", zap.String("overlay", diff.Overlay()), zap.String("name", diff.Name), zap.String("kind", string(diff.Kind)), zap.String("tag1", diff.Tag1), zap.String("tag2", diff.Tag2))
+
+		// only the digest or the registry changed, there's nothing new to list
+		if diff.Kind == git.ImageDigest || (diff.Kind == git.ImageRenamed && diff.Tag1 == diff.Tag2) {
 			continue
 		}
-		results = append(results, res)
+
+		image := diff.Image2()
+		if diff.Kind == git.ImageRemoved {
+			image = diff.Image1()
+		}
+
+		repoName := git.ExtractRepoName(cfg.Images.RepoPrefix, image)
+		if repoName == "" {
+			continue
+		}
+
+		// renamed to another repo, it's a new service as far as the notes are concerned
+		tag1 := diff.Tag1
+		if diff.Kind == git.ImageRenamed && git.ExtractRepoName(cfg.Images.RepoPrefix, diff.Image1()) != repoName {
+			tag1 = ""
+		}
+
+		change := imageChange{repoName: repoName, image: image, tag1: tag1, tag2: diff.Tag2}
+		if seen[change] {
+			continue
+		}
+
+		seen[change] = true
+		changes = append(changes, change)
 	}
 
-	return WrapReleaseWithEnvTemplate(ReleaseNoteToString(logger, results...))
+	return changes
 }
 
-func ReleaseNoteToString(logger *zap.Logger, notes ...ReleaseNote) string {
+func ReleaseNoteToString(logger *zap.Logger, cfg *config.Config, notes ...ReleaseNote) string {
+	return renderNotes(logger, cfg, nil, notes...)
+}
+
+// renderNotes renders the notes, preceded by the changes in each overlay when there are images.
+func renderNotes(logger *zap.Logger, cfg *config.Config, images []git.ImageDiff, notes ...ReleaseNote) string {
 	body := strings.Builder{}
 	body.Write([]byte("## Release Notes\n\n"))
+
+	release := NewReleaseTemplate(cfg, "", notes...)
+	release.Deployments = newDeployments(cfg, images)
+
+	if len(release.Deployments) > 0 {
+		body.WriteString(renderBanner(logger, cfg, deploymentsTemplate, release))
+	}
+
+	// rollbacks are always called out, they're easy to miss at the bottom of a long PR
+	if len(release.Rollbacks) > 0 {
+		body.WriteString(renderBanner(logger, cfg, rollbackTemplate, release))
+	}
+
+	// when grouping by type, breaking changes are hoisted above every repo
+	if cfg.Templates.GroupBy == config.GroupByType && len(release.Breaking) > 0 {
+		body.WriteString(renderBanner(logger, cfg, breakingTemplate, release))
+	}
+
+	// updated repos come first without a heading of their own, followed by each section
+	sections := map[string][]ReleaseNote{}
 	for _, note := range notes {
-		resString, err := note.String()
-		if err != nil {
-			logger.Error("failed to get release note for repo", zap.String("repo name", note.RepoName), zap.Error(err))
+		section := sectionFor(note)
+		sections[section] = append(sections[section], note)
+	}
+
+	for _, section := range sectionOrder {
+		if len(sections[section]) == 0 {
 			continue
 		}
-		body.WriteString(resString + "\n")
+
+		if section != "" {
+			body.WriteString(section + "\n\n")
+		}
+
+		for _, note := range sections[section] {
+			writeNote(logger, cfg, &body, note)
+		}
 	}
 
 	return body.String()
 }
+
+const (
+	sectionNew      = "## New Services"
+	sectionRemoved  = "## Removed Services"
+	sectionReverted = "## Reverted"
+)
+
+var sectionOrder = []string{"", sectionNew, sectionRemoved, sectionReverted}
+
+func sectionFor(note ReleaseNote) string {
+	switch {
+	case note.Rollback:
+		return sectionReverted
+	case note.Kind == git.ImageAdded:
+		return sectionNew
+	case note.Kind == git.ImageRemoved:
+		return sectionRemoved
+	}
+
+	return ""
+}
+
+func writeNote(logger *zap.Logger, cfg *config.Config, body *strings.Builder, note ReleaseNote) {
+	resString, err := note.String(cfg)
+	if err != nil {
+		logger.Error("failed to get release note for repo", zap.String("repo name", note.RepoName), zap.Error(err))
+		return
+	}
+	body.WriteString(resString + "\n")
+}
+
+// renderBanner renders a release-wide template shown above the repos, an error is logged and renders nothing.
+func renderBanner(logger *zap.Logger, cfg *config.Config, name string, release ReleaseTemplate) string {
+	tmpl, err := loadTemplate(cfg, name)
+	if err != nil {
+		logger.Error("failed to parse template", zap.String("template", name), zap.Error(err))
+		return ""
+	}
+
+	var tpl strings.Builder
+	if err := tmpl.Execute(&tpl, release); err != nil {
+		logger.Error("failed to execute template", zap.String("template", name), zap.Error(err))
+		return ""
+	}
+
+	return tpl.String()
+}
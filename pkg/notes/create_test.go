@@ -0,0 +1,26 @@
+package notes
+
+import (
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestUniqueChanges(t *testing.T) {
+	diffs := []git.ImageDiff{
+		{Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/some-service", Kind: git.ImageRetagged, Tag1: "1.2.3", Tag2: "1.3.0"},
+		{Environment: "prod", Namespace: "wb-other", Name: "adarga/some-service", Kind: git.ImageRetagged, Tag1: "1.2.3", Tag2: "1.3.0"},
+		{Environment: "stage", Namespace: "wb-lfqa", Name: "adarga/some-service", Kind: git.ImageRetagged, Tag1: "1.3.0", Tag2: "1.4.0"},
+		{Environment: "stage", Namespace: "wb-lfqa", Name: "adarga/pinned", Kind: git.ImageDigest, Tag1: "1.0.0", Tag2: "1.0.0"},
+		{Environment: "stage", Namespace: "wb-lfqa", Name: "bitnami/redis", Kind: git.ImageRetagged, Tag1: "7.0", Tag2: "7.2"},
+	}
+
+	// the same tag range in two namespaces is only fetched once
+	assert.Equal(t, []imageChange{
+		{repoName: "some-service", image: "adarga/some-service", tag1: "1.2.3", tag2: "1.3.0"},
+		{repoName: "some-service", image: "adarga/some-service", tag1: "1.3.0", tag2: "1.4.0"},
+	}, uniqueChanges(zap.NewNop(), config.Default(), diffs))
+}
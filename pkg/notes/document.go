@@ -0,0 +1,240 @@
+package notes
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"sigs.k8s.io/yaml"
+)
+
+// SchemaVersion is bumped whenever a field in Document is removed or changes meaning,
+// adding fields doesn't require a new version.
+const SchemaVersion = "1"
+
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatJSON     Format = "json"
+	FormatYAML     Format = "yaml"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatMarkdown, FormatJSON, FormatYAML:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("unknown format %q, expected one of markdown, json or yaml", s)
+}
+
+// Document is the structured form of a release, for consumption by other tools.
+type Document struct {
+	SchemaVersion string          `json:"schemaVersion"`
+	Images        []ImageDocument `json:"images,omitempty"`
+	Repos         []RepoDocument  `json:"repos"`
+}
+
+type ImageDocument struct {
+	// Path is the kustomization.yaml, Environment and Namespace are taken from it.
+	Path        string `json:"path"`
+	Environment string `json:"environment,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name"`
+	// Kind is added, removed, retagged, renamed or digest.
+	Kind string `json:"kind"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// FromImage and ToImage are only set when the image is renamed with newName.
+	FromImage  string `json:"fromImage,omitempty"`
+	ToImage    string `json:"toImage,omitempty"`
+	FromDigest string `json:"fromDigest,omitempty"`
+	ToDigest   string `json:"toDigest,omitempty"`
+}
+
+type RepoDocument struct {
+	Name    string           `json:"name"`
+	URL     string           `json:"url"`
+	Image   string           `json:"image,omitempty"`
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Issues  []IssueDocument  `json:"issues"`
+	Commits []CommitDocument `json:"commits"`
+	// Untracked are the SHAs of the commits that aren't attached to any issue.
+	Untracked []string `json:"untracked"`
+	// Rollback is set when To is older than From, the issues and commits are the ones being removed.
+	Rollback bool `json:"rollback,omitempty"`
+	// Kind is added when the image is new, From is empty and the commits are the last ones up to To.
+	// It's removed when the image is gone, To is empty and there are no commits.
+	Kind string `json:"kind,omitempty"`
+}
+
+type IssueDocument struct {
+	Key     string   `json:"key"`
+	Summary string   `json:"summary"`
+	Status  string   `json:"status"`
+	Type    string   `json:"type,omitempty"`
+	Labels  []string `json:"labels"`
+	URL     string   `json:"url"`
+	// Commits are the SHAs of the commits referencing the issue.
+	Commits []string `json:"commits"`
+}
+
+type CommitDocument struct {
+	SHA     string    `json:"sha"`
+	Subject string    `json:"subject"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	PR      int       `json:"pr,omitempty"`
+	// PullRequest is the PR the commit was merged in, only set when resolved through GitHub.
+	PullRequest *PullRequestDocument `json:"pullRequest,omitempty"`
+	// Type, Scope and Breaking are only set for conventional commits.
+	Type     string `json:"type,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	Breaking bool   `json:"breaking,omitempty"`
+}
+
+type PullRequestDocument struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author"`
+	Labels    []string  `json:"labels"`
+	MergedAt  time.Time `json:"mergedAt"`
+	Reviewers []string  `json:"reviewers"`
+	URL       string    `json:"url"`
+}
+
+// NewDocument converts the release into its structured form.
+func NewDocument(cfg *config.Config, release Release) Document {
+	doc := Document{
+		SchemaVersion: SchemaVersion,
+		Images:        make([]ImageDocument, 0, len(release.Images)),
+		Repos:         make([]RepoDocument, 0, len(release.Notes)),
+	}
+
+	for _, image := range release.Images {
+		doc.Images = append(doc.Images, ImageDocument{
+			Path:        image.Path,
+			Environment: image.Environment,
+			Namespace:   image.Namespace,
+			Name:        image.Name,
+			Kind:        string(image.Kind),
+			From:        image.Tag1,
+			To:          image.Tag2,
+			FromImage:   image.NewName1,
+			ToImage:     image.NewName2,
+			FromDigest:  image.Digest1,
+			ToDigest:    image.Digest2,
+		})
+	}
+
+	for _, note := range release.Notes {
+		if note.RepoName == "" {
+			continue
+		}
+
+		repo := RepoDocument{
+			Name:      note.RepoName,
+			URL:       cfg.RepoURL(note.RepoName),
+			Image:     note.Image,
+			From:      note.Tag1,
+			To:        note.Tag2,
+			Issues:    make([]IssueDocument, 0, len(note.Issues)),
+			Commits:   make([]CommitDocument, 0, len(note.Commits)),
+			Untracked: make([]string, 0, len(note.Untracked)),
+			Rollback:  note.Rollback,
+			Kind:      string(note.Kind),
+		}
+
+		for _, ic := range note.Issues {
+			issue := ic.Issue
+			shas := make([]string, 0, len(ic.Commits))
+			for _, commit := range ic.Commits {
+				shas = append(shas, commit.Hash.String())
+			}
+
+			labels := issue.Labels
+			if labels == nil {
+				labels = []string{}
+			}
+
+			repo.Issues = append(repo.Issues, IssueDocument{
+				Key:     issue.Key,
+				Summary: issue.Summary,
+				Status:  issue.Status,
+				Type:    issue.Type,
+				Labels:  labels,
+				URL:     issue.URL,
+				Commits: shas,
+			})
+		}
+
+		for _, commit := range note.Commits {
+			subject := strings.Split(commit.Message, "\n")[0]
+			cc, _ := git.ParseConventionalCommit(commit.Message)
+
+			pr := 0
+			var pullRequest *PullRequestDocument
+			if prs := note.pullRequests(cfg, commit); len(prs) > 0 {
+				pr = prs[0].Number
+				if note.PullRequests != nil {
+					pullRequest = &PullRequestDocument{
+						Number:    prs[0].Number,
+						Title:     prs[0].Title,
+						Author:    prs[0].Author,
+						Labels:    prs[0].Labels,
+						MergedAt:  prs[0].MergedAt,
+						Reviewers: prs[0].Reviewers,
+						URL:       prs[0].URL,
+					}
+				}
+			}
+
+			repo.Commits = append(repo.Commits, CommitDocument{
+				SHA:         commit.Hash.String(),
+				Subject:     subject,
+				Author:      commit.Author.Name,
+				Email:       commit.Author.Email,
+				Date:        commit.Author.When.UTC(),
+				PR:          pr,
+				PullRequest: pullRequest,
+				Type:        cc.Type,
+				Scope:       cc.Scope,
+				Breaking:    cc.Breaking,
+			})
+		}
+
+		for _, commit := range note.Untracked {
+			repo.Untracked = append(repo.Untracked, commit.Hash.String())
+		}
+
+		doc.Repos = append(doc.Repos, repo)
+	}
+
+	return doc
+}
+
+// Encode marshals the document as JSON or YAML.
+func (d Document) Encode(format Format) (string, error) {
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal json: %w", err)
+		}
+		return string(data) + "\n", nil
+	case FormatYAML:
+		data, err := yaml.Marshal(d)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal yaml: %w", err)
+		}
+		return string(data), nil
+	}
+
+	return "", fmt.Errorf("format %s can't be used to encode a document", format)
+}
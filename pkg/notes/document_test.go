@@ -0,0 +1,107 @@
+package notes_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/alex-emery/release-notes/pkg/notes"
+	"github.com/alex-emery/release-notes/pkg/tracker"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func testRelease() notes.Release {
+	commit := object.Commit{
+		Hash:    plumbing.NewHash("3eb443b0d4b0a6c8b3f1b2f6e2f1c1a3b5d7e9f1"),
+		Message: "feat(APP-1): support answer rating (#174)\n\nsome body",
+		Author: object.Signature{
+			Name:  "Some Dev",
+			Email: "dev@example.com",
+			When:  time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	issue := &tracker.Issue{
+		Key:     "APP-1",
+		Summary: "Support answer rating",
+		Status:  "Done",
+		Type:    "Story",
+		Labels:  []string{"backend"},
+		URL:     "https://adarga.atlassian.net/browse/APP-1",
+	}
+
+	return notes.Release{
+		Images: []git.ImageDiff{{
+			Path:        "environments/engine-prod/baseline/wb-lfqa/kustomization.yaml",
+			Environment: "prod",
+			Namespace:   "wb-lfqa",
+			Name:        "adarga/some-service",
+			Kind:        git.ImageRetagged,
+			Tag1:        "1.0.0",
+			Tag2:        "1.1.0",
+		}},
+		Notes: []notes.ReleaseNote{{
+			RepoName: "some-service",
+			Image:    "adarga/some-service",
+			Tag1:     "1.0.0",
+			Tag2:     "1.1.0",
+			Issues:   []notes.IssueCommits{{Issue: issue, Commits: []object.Commit{commit}}},
+			Commits:  []object.Commit{commit},
+		}},
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc := notes.NewDocument(config.Default(), testRelease())
+
+	for _, format := range []notes.Format{notes.FormatJSON, notes.FormatYAML} {
+		out, err := doc.Encode(format)
+		require.NoError(t, err)
+
+		decoded := notes.Document{}
+		if format == notes.FormatJSON {
+			require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+		} else {
+			require.NoError(t, yaml.Unmarshal([]byte(out), &decoded))
+		}
+
+		assert.Equal(t, notes.SchemaVersion, decoded.SchemaVersion)
+		require.Len(t, decoded.Repos, 1)
+
+		repo := decoded.Repos[0]
+		assert.Equal(t, "https://github.com/Adarga-Ltd/some-service", repo.URL)
+		assert.Equal(t, "1.0.0", repo.From)
+		assert.Equal(t, "1.1.0", repo.To)
+		require.Len(t, repo.Issues, 1)
+		assert.Equal(t, []string{"3eb443b0d4b0a6c8b3f1b2f6e2f1c1a3b5d7e9f1"}, repo.Issues[0].Commits)
+		require.Len(t, repo.Commits, 1)
+		assert.Equal(t, 174, repo.Commits[0].PR)
+		assert.Equal(t, "feat(APP-1): support answer rating (#174)", repo.Commits[0].Subject)
+		assert.Equal(t, "feat", repo.Commits[0].Type)
+		assert.Equal(t, "APP-1", repo.Commits[0].Scope)
+		assert.Equal(t, []notes.ImageDocument{{
+			Path:        "environments/engine-prod/baseline/wb-lfqa/kustomization.yaml",
+			Environment: "prod",
+			Namespace:   "wb-lfqa",
+			Name:        "adarga/some-service",
+			Kind:        "retagged",
+			From:        "1.0.0",
+			To:          "1.1.0",
+		}}, decoded.Images)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := notes.ParseFormat("JSON")
+	require.NoError(t, err)
+	assert.Equal(t, notes.FormatJSON, format)
+
+	_, err = notes.ParseFormat("html")
+	require.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package notes_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/notes"
+	"github.com/alex-emery/release-notes/pkg/tracker"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// assertGolden compares actual against testdata/<name>.golden, rewriting it when -update is set.
+func assertGolden(t *testing.T, name, actual string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.NoError(t, os.WriteFile(path, []byte(actual), 0644))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), actual)
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	testCases := []struct {
+		summary string
+		labels  []string
+	}{
+		{
+			summary: `Search & filter returns "quotes" and isn't <escaped>`,
+			labels:  []string{"R&D"},
+		},
+		{
+			summary: "[FE] Fix `useQuery` | table layout",
+			labels:  []string{"front_end", "tech-debt"},
+		},
+		{
+			summary: "# of results shown as *NaN* for_empty queries",
+			labels:  []string{"#bugs"},
+		},
+		{
+			summary: "Link to https://adarga.atlassian.net/wiki/spaces/PLAT/pages/3192946704/Using_Stackhawk breaks\nover lines",
+			labels:  []string{},
+		},
+		{
+			summary: `Path C:\temp\[id] isn't > 100 chars`,
+			labels:  nil,
+		},
+	}
+
+	cfg := config.Default()
+	out := ""
+	for _, tc := range testCases {
+		note := notes.ReleaseNote{
+			RepoName: "some-service",
+			Issues: []notes.IssueCommits{{
+				Issue: &tracker.Issue{
+					Key:     "APP-1",
+					Summary: tc.summary,
+					Status:  "In Progress",
+					Labels:  tc.labels,
+					URL:     "https://adarga.atlassian.net/browse/APP-1",
+				},
+				Commits: []object.Commit{{Message: "feat(APP-1): thing (#12)"}},
+			}},
+			Commits: []object.Commit{},
+		}
+
+		rendered, err := note.String(cfg)
+		require.NoError(t, err)
+		out += rendered + "\n\n"
+	}
+
+	assertGolden(t, "escaping", out)
+}
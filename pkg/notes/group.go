@@ -0,0 +1,64 @@
+package notes
+
+// The groups commits are split into when grouping by type, in the order they're rendered.
+const (
+	GroupBreaking    = "Breaking Changes"
+	GroupFeatures    = "Features"
+	GroupFixes       = "Fixes"
+	GroupPerformance = "Performance"
+	GroupOther       = "Other"
+)
+
+var groupOrder = []string{GroupBreaking, GroupFeatures, GroupFixes, GroupPerformance, GroupOther}
+
+// CommitGroup is a set of commits sharing a conventional commit type, i.e Features.
+type CommitGroup struct {
+	Name    string
+	Commits []CommitTemplate
+}
+
+// BreakingChange is a breaking commit hoisted into the release-wide banner.
+type BreakingChange struct {
+	// RepoName is formatted for display, i.e Some Service
+	RepoName string
+	RepoURL  string
+	Commit   CommitTemplate
+}
+
+// groupName returns the group a commit belongs in, breaking changes take precedence over their type.
+func groupName(commit CommitTemplate) string {
+	if commit.Breaking {
+		return GroupBreaking
+	}
+
+	switch commit.Type {
+	case "feat":
+		return GroupFeatures
+	case "fix":
+		return GroupFixes
+	case "perf":
+		return GroupPerformance
+	}
+
+	return GroupOther
+}
+
+// groupCommits splits commits by type, keeping their order within a group and leaving out empty groups.
+func groupCommits(commits []CommitTemplate) []CommitGroup {
+	byName := map[string][]CommitTemplate{}
+	for _, commit := range commits {
+		name := groupName(commit)
+		byName[name] = append(byName[name], commit)
+	}
+
+	groups := []CommitGroup{}
+	for _, name := range groupOrder {
+		if len(byName[name]) == 0 {
+			continue
+		}
+
+		groups = append(groups, CommitGroup{Name: name, Commits: byName[name]})
+	}
+
+	return groups
+}
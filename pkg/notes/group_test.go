@@ -0,0 +1,63 @@
+package notes_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/notes"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// conventional builds commits with predictable SHAs, i.e 1000000…, 2000000…
+func conventional(messages ...string) []object.Commit {
+	result := make([]object.Commit, 0, len(messages))
+	for i, message := range messages {
+		result = append(result, object.Commit{
+			Hash:    plumbing.NewHash(fmt.Sprintf("%d%039d", i+1, 0)),
+			Message: message,
+			Author:  object.Signature{Name: "Some Dev"},
+		})
+	}
+
+	return result
+}
+
+func TestGroupByType(t *testing.T) {
+	cfg := config.Default()
+	cfg.Templates.GroupBy = config.GroupByType
+
+	merge := conventional("Merge branch 'main' into APP-7")[0]
+	merge.ParentHashes = []plumbing.Hash{plumbing.ZeroHash, plumbing.ZeroHash}
+
+	release := notes.Release{
+		Notes: []notes.ReleaseNote{
+			{
+				RepoName: "some-service",
+				Commits: append(conventional(
+					"feat(APP-1): support answer rating (#174)",
+					"fix: handle empty *tags* (#175)",
+					"perf(search): cache embeddings",
+					"refactor(api)!: drop the v1 endpoints (#176)",
+					"chore(deps): bump zap",
+					"APP-9 tidy up",
+				), merge),
+			},
+			{
+				RepoName: "other-service",
+				Commits: conventional(
+					"feat: new config format\n\nBREAKING CHANGE: config.yaml must be migrated\nwith the migrate command",
+					"fix(APP-3): typo",
+				),
+			},
+		},
+	}
+
+	out, err := release.Markdown(zap.NewNop(), cfg)
+	require.NoError(t, err)
+
+	assertGolden(t, "grouped", out)
+}
@@ -0,0 +1,45 @@
+package notes
+
+import (
+	"regexp"
+	"strings"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>()\[\]]+`)
+
+// characters that change how the surrounding text renders in Markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `\<`,
+	`>`, `\>`,
+	`|`, `\|`,
+)
+
+// EscapeMarkdown escapes s so it renders literally when placed in Markdown,
+// i.e a Jira summary containing [brackets], `backticks` or | pipes.
+// URLs are left as they are so they stay clickable.
+func EscapeMarkdown(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+
+	escaped := strings.Builder{}
+	last := 0
+	for _, loc := range urlPattern.FindAllStringIndex(s, -1) {
+		escaped.WriteString(markdownEscaper.Replace(s[last:loc[0]]))
+		escaped.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	escaped.WriteString(markdownEscaper.Replace(s[last:]))
+
+	// a leading # turns the line into a heading
+	result := escaped.String()
+	if strings.HasPrefix(result, "#") {
+		result = `\` + result
+	}
+
+	return result
+}
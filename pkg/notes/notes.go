@@ -3,49 +3,186 @@ package notes
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"html/template"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/alex-emery/release-notes/pkg/config"
 	"github.com/alex-emery/release-notes/pkg/git"
-	jira "github.com/andygrunwald/go-jira/v2/cloud"
+	"github.com/alex-emery/release-notes/pkg/github"
+	"github.com/alex-emery/release-notes/pkg/tracker"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"go.uber.org/zap"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
-type IssueCommitMap map[*jira.Issue][]object.Commit
+// IssueCommits is an issue and the commits referencing it.
+type IssueCommits struct {
+	Issue   *tracker.Issue
+	Commits []object.Commit
+}
+
 type ReleaseNote struct {
 	RepoName string
-	Issues   IssueCommitMap
+	// Image is the image the notes were created for, empty when created straight from a repo.
+	Image   string
+	Tag1    string
+	Tag2    string
+	Issues  []IssueCommits
+	Commits []object.Commit
+	// Untracked are the commits that aren't attached to any issue.
+	Untracked []object.Commit
+	// PullRequests are the PRs each commit was merged in, keyed by SHA.
+	// Nil when they weren't resolved through GitHub.
+	PullRequests map[string][]github.PullRequest
+	// Rollback is set when Tag2 is older than Tag1, the issues and commits are the ones being removed.
+	Rollback bool
+	// Kind is added for a newly deployed image, listing the last commits up to Tag2,
+	// and removed for a decommissioned image, which has no commits.
+	Kind git.ChangeKind
 }
 
-// all the fields for printing the template.
+// all the fields for printing the notes template, one per repo.
 type PRTemplate struct {
+	// RepoName is formatted for display, i.e Some Service
 	RepoName string
-	RepoURL  string
-	Issues   []IssueTemplate
+	// Name is the repo name, i.e some-service
+	Name    string
+	RepoURL string
+	Image   string
+	Tag1    string
+	Tag2    string
+	Issues  []IssueTemplate
+	Commits []CommitTemplate
+	// Untracked are the commits that aren't attached to any issue.
+	Untracked []CommitTemplate
+	// Groups are the commits, less merge commits, split by conventional commit type.
+	Groups []CommitGroup
+	// Breaking are the commits marked as breaking changes.
+	Breaking []CommitTemplate
+	// Rollback is set when Tag2 is older than Tag1, everything listed is being removed.
+	Rollback bool
+	// Kind is added, removed or retagged.
+	Kind string
+	// Authors are the unique commit authors, sorted by name.
+	Authors []string
 }
 
 type IssueTemplate struct {
 	ID      string
+	URL     string
 	Summary string
 	Status  string
+	Type    string
 	Labels  []string
-	PRs     []string
+	// PRs are the PR numbers, kept for templates written before PullRequests.
+	PRs          []string
+	PullRequests []PullRequestTemplate
+}
+
+type CommitTemplate struct {
+	SHA      string
+	ShortSHA string
+	Subject  string
+	Author   string
+	Email    string
+	Date     time.Time
+	PR       string
+	// PullRequest is the PR the commit was merged in, nil when there isn't one.
+	PullRequest *PullRequestTemplate
+	// Type, Scope and Description are parsed from a conventional commit subject,
+	// Description is the whole subject otherwise. Either way the PR suffix is dropped from Description.
+	Type         string
+	Scope        string
+	Description  string
+	Breaking     bool
+	BreakingNote string
+}
+
+// all the fields for printing the env template, which wraps the notes for every repo.
+type ReleaseTemplate struct {
+	// Notes are the rendered notes for every repo.
+	Notes  string
+	Repos  []PRTemplate
+	Totals Totals
+	// Breaking are the breaking changes across every repo, leaving out rollbacks.
+	Breaking []BreakingChange
+	// Rollbacks are the repos being rolled back.
+	Rollbacks []PRTemplate
+	// Deployments are the image changes in each overlay, only set for k8s-engine diffs.
+	Deployments []DeploymentTemplate
+}
+
+// DeploymentTemplate is an image change within a single environment and namespace.
+type DeploymentTemplate struct {
+	// Overlay is the environment and namespace, i.e prod / wb-lfqa
+	Overlay     string
+	Environment string
+	Namespace   string
+	Path        string
+	// Name is the repo name, or the image when it isn't one of ours.
+	Name  string
+	Image string
+	Kind  string
+	Tag1  string
+	Tag2  string
+}
+
+// newDeployments lists the image changes by overlay, leaving out digest only changes.
+func newDeployments(cfg *config.Config, images []git.ImageDiff) []DeploymentTemplate {
+	deployments := make([]DeploymentTemplate, 0, len(images))
+	for _, image := range images {
+		if image.Kind == git.ImageDigest {
+			continue
+		}
+
+		name := image.Image2()
+		if image.Kind == git.ImageRemoved {
+			name = image.Image1()
+		}
+
+		if repoName := git.ExtractRepoName(cfg.Images.RepoPrefix, name); repoName != "" {
+			name = repoName
+		}
+
+		deployments = append(deployments, DeploymentTemplate{
+			Overlay:     image.Overlay(),
+			Environment: image.Environment,
+			Namespace:   image.Namespace,
+			Path:        image.Path,
+			Name:        name,
+			Image:       image.Name,
+			Kind:        string(image.Kind),
+			Tag1:        image.Tag1,
+			Tag2:        image.Tag2,
+		})
+	}
+
+	return deployments
+}
+
+type Totals struct {
+	Repos     int
+	Issues    int
+	Commits   int
+	Untracked int
+	Authors   int
 }
 
 // Just wraps the relase with the env template.
-func WrapReleaseWithEnvTemplate(content string) (string, error) {
-	tmpl, err := template.ParseFS(templateFS, "env.template")
+func WrapReleaseWithEnvTemplate(cfg *config.Config, release ReleaseTemplate) (string, error) {
+	tmpl, err := loadTemplate(cfg, envTemplate)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template : %v", err)
 	}
 
 	// execute the struct against the template
 	var tpl bytes.Buffer
-	err = tmpl.Execute(&tpl, content)
+	err = tmpl.Execute(&tpl, release)
 	if err != nil {
 		return "", fmt.Errorf("failed to execute template : %v", err)
 	}
@@ -53,35 +190,157 @@ func WrapReleaseWithEnvTemplate(content string) (string, error) {
 	return tpl.String(), nil
 }
 
-// Print issue.
-func (rn ReleaseNote) String() (string, error) {
+// NewReleaseTemplate gathers the data for the env template, notes is the already rendered notes.
+func NewReleaseTemplate(cfg *config.Config, notes string, releaseNotes ...ReleaseNote) ReleaseTemplate {
+	release := ReleaseTemplate{
+		Notes: notes,
+		Repos: make([]PRTemplate, 0, len(releaseNotes)),
+	}
+
+	authors := map[string]bool{}
+	for _, rn := range releaseNotes {
+		if rn.RepoName == "" {
+			continue
+		}
+
+		repo := rn.templateData(cfg)
+		release.Repos = append(release.Repos, repo)
+		release.Totals.Issues += len(repo.Issues)
+		release.Totals.Commits += len(repo.Commits)
+		release.Totals.Untracked += len(repo.Untracked)
+		if repo.Rollback {
+			release.Rollbacks = append(release.Rollbacks, repo)
+			continue
+		}
+
+		for _, commit := range repo.Breaking {
+			release.Breaking = append(release.Breaking, BreakingChange{RepoName: repo.RepoName, RepoURL: repo.RepoURL, Commit: commit})
+		}
+		for _, author := range repo.Authors {
+			authors[author] = true
+		}
+	}
+
+	release.Totals.Repos = len(release.Repos)
+	release.Totals.Authors = len(authors)
+
+	return release
+}
+
+func (rn ReleaseNote) templateData(cfg *config.Config) PRTemplate {
 	pr := PRTemplate{
-		RepoURL:  "https://github.com/Adarga-Ltd/" + rn.RepoName,
+		RepoURL:  cfg.RepoURL(rn.RepoName),
 		RepoName: formatRepoName(rn.RepoName),
+		Name:     rn.RepoName,
+		Image:    rn.Image,
+		Tag1:     rn.Tag1,
+		Tag2:     rn.Tag2,
+		Rollback: rn.Rollback,
+		Kind:     string(rn.Kind),
 		Issues:   make([]IssueTemplate, 0, len(rn.Issues)),
+		Commits:  make([]CommitTemplate, 0, len(rn.Commits)),
+		Authors:  []string{},
 	}
 
-	for issue, commits := range rn.Issues {
+	for _, ic := range rn.Issues {
+		issue := ic.Issue
 		currentIssue := IssueTemplate{
 			ID:      issue.Key,
-			Labels:  issue.Fields.Labels,
-			Summary: issue.Fields.Summary,
-			Status:  issue.Fields.Status.Name,
+			URL:     issue.URL,
+			Labels:  issue.Labels,
+			Summary: issue.Summary,
+			Status:  issue.Status,
+			Type:    issue.Type,
 			PRs:     []string{},
 		}
 
-		for _, commit := range commits {
+		prs := []PullRequestTemplate{}
+		for _, commit := range ic.Commits {
+			prs = append(prs, rn.pullRequests(cfg, commit)...)
+		}
 
-			// get PR number from commit message
-			prNumber := git.ExtractPR(strings.Split(commit.Message, "\n")[0])
-			currentIssue.PRs = append(currentIssue.PRs, prNumber)
+		currentIssue.PullRequests = uniquePullRequests(prs)
+		for _, pr := range currentIssue.PullRequests {
+			currentIssue.PRs = append(currentIssue.PRs, strconv.Itoa(pr.Number))
 		}
 
 		pr.Issues = append(pr.Issues, currentIssue)
 	}
 
+	seen := map[string]bool{}
+	grouped := []CommitTemplate{}
+	for _, commit := range rn.Commits {
+		ct := rn.newCommitTemplate(cfg, commit)
+		pr.Commits = append(pr.Commits, ct)
+
+		if len(commit.ParentHashes) <= 1 {
+			grouped = append(grouped, ct)
+		}
+
+		if ct.Breaking {
+			pr.Breaking = append(pr.Breaking, ct)
+		}
+
+		if !seen[commit.Author.Name] {
+			seen[commit.Author.Name] = true
+			pr.Authors = append(pr.Authors, commit.Author.Name)
+		}
+	}
+
+	for _, commit := range rn.Untracked {
+		pr.Untracked = append(pr.Untracked, rn.newCommitTemplate(cfg, commit))
+	}
+	sort.Strings(pr.Authors)
+	pr.Groups = groupCommits(grouped)
+
+	return pr
+}
+
+func (rn ReleaseNote) newCommitTemplate(cfg *config.Config, commit object.Commit) CommitTemplate {
+	subject := strings.Split(commit.Message, "\n")[0]
+	sha := commit.Hash.String()
+
+	ct := CommitTemplate{
+		SHA:         sha,
+		ShortSHA:    sha[:7],
+		Subject:     subject,
+		Author:      commit.Author.Name,
+		Email:       commit.Author.Email,
+		Date:        commit.Author.When,
+		Description: subject,
+	}
+
+	if prs := rn.pullRequests(cfg, commit); len(prs) > 0 {
+		ct.PullRequest = &prs[0]
+		ct.PR = strconv.Itoa(prs[0].Number)
+	}
+
+	if cc, ok := git.ParseConventionalCommit(commit.Message); ok {
+		ct.Type = cc.Type
+		ct.Scope = cc.Scope
+		ct.Description = cc.Description
+		ct.Breaking = cc.Breaking
+		ct.BreakingNote = cc.BreakingNote
+	}
+
+	if pr := git.ExtractPR(subject); pr != "" {
+		ct.Description = strings.TrimSuffix(ct.Description, " (#"+pr+")")
+	}
+
+	return ct
+}
+
+// Print issue.
+func (rn ReleaseNote) String(cfg *config.Config) (string, error) {
+	pr := rn.templateData(cfg)
+
+	name := notesTemplate
+	if cfg.Templates.GroupBy == config.GroupByType {
+		name = groupedTemplate
+	}
+
 	// read in the template
-	tmpl, err := template.ParseFS(templateFS, "notes.template")
+	tmpl, err := loadTemplate(cfg, name)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template : %v", err)
 	}
@@ -109,41 +368,189 @@ func formatRepoName(s string) string {
 	return strings.Join(words, " ")
 }
 
-func CreateReleaseNotesForRepo(ctx context.Context, logger *zap.Logger, jiraClient *jira.Client, gitAuth *git.Auth, repoName string, tag1 string, tag2 string) ReleaseNote {
+// repoCommits are the commits of a repo grouped by tracker key, before the issues have been fetched.
+type repoCommits struct {
+	repoName string
+	image    string
+	tag1     string
+	tag2     string
+	tracker  string
+	commits  []object.Commit
+	keys     map[string][]object.Commit
+	rollback bool
+	kind     git.ChangeKind
+	// pullRequests are keyed by commit SHA, nil when they weren't resolved.
+	pullRequests map[string][]github.PullRequest
+}
+
+// CreateReleaseNotesForRepo creates the notes between two tags of a repo, pulls may be nil.
+// It fails when the repo's commits can't be collected.
+func CreateReleaseNotesForRepo(ctx context.Context, logger *zap.Logger, cfg *config.Config, trackers tracker.Trackers, pulls PullRequestResolver, gitAuth *git.Auth, repoName string, tag1 string, tag2 string) (ReleaseNote, error) {
+	repo, err := collectRepoCommits(logger, cfg, trackers, gitAuth, repoName, tag1, tag2)
+	if err != nil {
+		return ReleaseNote{}, fmt.Errorf("failed to get commits for %s: %w", repoName, err)
+	}
+
+	lookupPullRequests(ctx, logger, pulls, &repo)
+
+	return resolveIssues(ctx, logger, cfg, trackers, repo)[0], nil
+}
+
+// collectRepoCommits clones the repo and finds the tracker keys referenced between the tags.
+// An empty tag1 is a newly deployed image, listing the last commits up to tag2,
+// an empty tag2 is a removed image which has no commits.
+func collectRepoCommits(logger *zap.Logger, cfg *config.Config, trackers tracker.Trackers, gitAuth *git.Auth, repoName string, tag1 string, tag2 string) (repoCommits, error) {
 	logger = logger.With(zap.String("repo", repoName))
+
+	trackerName := cfg.TrackerFor(repoName)
+	issueTracker, err := trackers.Get(trackerName)
+	if err != nil {
+		return repoCommits{}, err
+	}
+
+	if tag2 == "" {
+		return repoCommits{
+			repoName: repoName,
+			tag1:     tag1,
+			tracker:  trackerName,
+			kind:     git.ImageRemoved,
+		}, nil
+	}
+
+	rules, err := git.NewTicketRules(cfg.TicketPatternsFor(repoName))
+	if err != nil {
+		return repoCommits{}, err
+	}
+
+	scheme, err := git.NewTagScheme(cfg.TagsFor(repoName))
+	if err != nil {
+		return repoCommits{}, err
+	}
+
 	repo, err := gitAuth.CloneRepo(repoName)
 	if err != nil {
-		logger.Error("failed to clone: skipping", zap.Error(err))
-		return ReleaseNote{}
+		return repoCommits{}, fmt.Errorf("failed to clone: %w", err)
+	}
+
+	kind := git.ImageRetagged
+	var commits []object.Commit
+	if tag1 == "" {
+		kind = git.ImageAdded
+		logger.Debug("getting commits up to tag", zap.String("tag2", tag2))
+		commits, err = git.CommitsUpToTag(repo, scheme, tag2, cfg.Images.NewCommits)
+	} else {
+		logger.Debug("getting commits between tags", zap.String("tag1", tag1), zap.String("tag2", tag2))
+		commits, err = git.CommitsBetweenTags(repo, scheme, tag1, tag2)
+	}
+
+	rollback := errors.Is(err, git.ErrRollback)
+	if rollback {
+		logger.Warn("image is being rolled back, listing the commits being removed", zap.String("tag1", tag1), zap.String("tag2", tag2))
+		commits, err = git.CommitsBetweenTags(repo, scheme, tag2, tag1)
 	}
 
-	logger.Debug("getting commits between tags", zap.String("tag1", tag1), zap.String("tag2", tag2))
-	commits, err := git.GetCommitsBetweenTags(repo, tag1, tag2)
 	if err != nil {
-		logger.Error("failed to get commits between tags", zap.String("tag1", tag1), zap.String("tag2", tag2), zap.Error(err))
-		return ReleaseNote{}
+		return repoCommits{}, fmt.Errorf("failed to get commits between tags %s and %s: %w", tag1, tag2, err)
 	}
 
-	issueCommitMap := make(IssueCommitMap)
-	uniqueIssues := git.CommitsToIssues(commits)
+	// references are turned into tracker keys, i.e #12 becomes org/repo#12 for GitHub Issues.
+	keys := make(map[string][]object.Commit)
+	for ref, commits := range git.CommitsToIssues(rules, commits) {
+		key := issueTracker.Key(repoName, ref)
+		keys[key] = append(keys[key], commits...)
+	}
+
+	logger.Debug("unique issues", zap.Int("count", len(keys)))
 
-	logger.Debug("unique issues", zap.Int("count", len(uniqueIssues)))
+	return repoCommits{
+		repoName: repoName,
+		tag1:     tag1,
+		tag2:     tag2,
+		tracker:  trackerName,
+		commits:  commits,
+		keys:     keys,
+		rollback: rollback,
+		kind:     kind,
+	}, nil
+}
 
-	for issueID, commits := range uniqueIssues {
-		logger.Debug("searching for issue ", zap.String("issueID", issueID))
+// resolveIssues fetches every unique key once per tracker, no matter how many repos reference it,
+// and builds a release note for each repo.
+func resolveIssues(ctx context.Context, logger *zap.Logger, cfg *config.Config, trackers tracker.Trackers, repos ...repoCommits) []ReleaseNote {
+	filter, err := newUntrackedFilter(cfg)
+	if err != nil {
+		logger.Error("failed to create untracked filter, nothing will be ignored", zap.Error(err))
+		filter = &untrackedFilter{}
+	}
+
+	trackerKeys := map[string]map[string]bool{}
+	for _, repo := range repos {
+		if trackerKeys[repo.tracker] == nil {
+			trackerKeys[repo.tracker] = map[string]bool{}
+		}
 
-		found, _, err := jiraClient.Issue.Get(ctx, issueID, nil)
+		for key := range repo.keys {
+			trackerKeys[repo.tracker][key] = true
+		}
+	}
+
+	found := map[string]map[string]*tracker.Issue{}
+	for name, uniqueKeys := range trackerKeys {
+		keys := make([]string, 0, len(uniqueKeys))
+		for key := range uniqueKeys {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		logger.Debug("fetching issues", zap.String("tracker", name), zap.Int("count", len(keys)))
+
+		issueTracker, err := trackers.Get(name)
 		if err != nil {
-			logger.Error("failed to find issue", zap.String("issueID", issueID), zap.Error(err))
+			logger.Error("failed to get issue tracker", zap.Error(err))
 			continue
 		}
 
-		issueCommitMap[found] = commits
+		issues, err := issueTracker.Issues(ctx, keys)
+		if err != nil {
+			logger.Error("failed to find issues", zap.String("tracker", name), zap.Error(err))
+			continue
+		}
 
+		found[name] = issues
 	}
 
-	return ReleaseNote{
-		RepoName: repoName,
-		Issues:   issueCommitMap,
+	notes := make([]ReleaseNote, 0, len(repos))
+	for _, repo := range repos {
+		// a key the tracker doesn't know, i.e a typo or a GitHub PR number, isn't tracked,
+		// but when the tracker couldn't be reached every referenced key is assumed to be.
+		trackerIssues, fetched := found[repo.tracker]
+		tracked := make(map[string][]object.Commit, len(repo.keys))
+		issues := make([]IssueCommits, 0, len(repo.keys))
+		for key, commits := range repo.keys {
+			issue, ok := trackerIssues[key]
+			if ok || !fetched {
+				tracked[key] = commits
+			}
+
+			if ok {
+				issues = append(issues, IssueCommits{Issue: issue, Commits: commits})
+			}
+		}
+		sortIssues(issues)
+
+		notes = append(notes, ReleaseNote{
+			RepoName:     repo.repoName,
+			Image:        repo.image,
+			Tag1:         repo.tag1,
+			Tag2:         repo.tag2,
+			Issues:       issues,
+			Commits:      repo.commits,
+			Untracked:    untrackedCommits(filter, repo.commits, tracked),
+			PullRequests: repo.pullRequests,
+			Rollback:     repo.rollback,
+			Kind:         repo.kind,
+		})
 	}
+
+	return notes
 }
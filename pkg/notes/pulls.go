@@ -0,0 +1,104 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/alex-emery/release-notes/pkg/github"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"go.uber.org/zap"
+)
+
+// PullRequestResolver finds the pull requests commits were merged in, see github.Client.
+type PullRequestResolver interface {
+	PullRequestsForCommits(ctx context.Context, repo string, shas []string) (map[string][]github.PullRequest, error)
+}
+
+type PullRequestTemplate struct {
+	Number int
+	URL    string
+	// Title, Author, Labels, MergedAt and Reviewers are only set when the PR was resolved through GitHub.
+	Title     string
+	Author    string
+	Labels    []string
+	MergedAt  time.Time
+	Reviewers []string
+}
+
+// lookupPullRequests resolves the PRs for every commit in the repo,
+// leaving them unset on failure so the (#123) suffix of the subject is used instead.
+func lookupPullRequests(ctx context.Context, logger *zap.Logger, pulls PullRequestResolver, repo *repoCommits) {
+	if pulls == nil {
+		return
+	}
+
+	shas := make([]string, 0, len(repo.commits))
+	for _, commit := range repo.commits {
+		shas = append(shas, commit.Hash.String())
+	}
+
+	prs, err := pulls.PullRequestsForCommits(ctx, repo.repoName, shas)
+	if err != nil {
+		logger.Error("failed to find pull requests, falling back to commit subjects", zap.String("repo", repo.repoName), zap.Error(err))
+		return
+	}
+
+	repo.pullRequests = prs
+}
+
+// pullRequests returns the PRs a commit was merged in. When the PRs weren't resolved through GitHub
+// the (#123) suffix of the subject is used, which misses rebase merged PRs.
+func (rn ReleaseNote) pullRequests(cfg *config.Config, commit object.Commit) []PullRequestTemplate {
+	if rn.PullRequests == nil {
+		number, err := strconv.Atoi(git.ExtractPR(strings.Split(commit.Message, "\n")[0]))
+		if err != nil {
+			return []PullRequestTemplate{}
+		}
+
+		return []PullRequestTemplate{{
+			Number: number,
+			URL:    fmt.Sprintf("%s/pull/%d", cfg.RepoURL(rn.RepoName), number),
+		}}
+	}
+
+	prs := rn.PullRequests[commit.Hash.String()]
+	result := make([]PullRequestTemplate, 0, len(prs))
+	for _, pr := range prs {
+		result = append(result, PullRequestTemplate{
+			Number:    pr.Number,
+			URL:       pr.URL,
+			Title:     pr.Title,
+			Author:    pr.Author,
+			Labels:    pr.Labels,
+			MergedAt:  pr.MergedAt,
+			Reviewers: pr.Reviewers,
+		})
+	}
+
+	return result
+}
+
+// uniquePullRequests drops repeated PRs, i.e several commits from one PR, and sorts them by number.
+func uniquePullRequests(prs []PullRequestTemplate) []PullRequestTemplate {
+	seen := map[int]bool{}
+	unique := make([]PullRequestTemplate, 0, len(prs))
+	for _, pr := range prs {
+		if seen[pr.Number] {
+			continue
+		}
+		seen[pr.Number] = true
+		unique = append(unique, pr)
+	}
+
+	sort.SliceStable(unique, func(i, j int) bool {
+		return unique[i].Number < unique[j].Number
+	})
+
+	return unique
+}
@@ -0,0 +1,70 @@
+package notes_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/github"
+	"github.com/alex-emery/release-notes/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvedPullRequests(t *testing.T) {
+	cfg := config.Default()
+
+	// a rebase merged commit has no (#123) suffix, the direct push has no PR at all
+	commits := conventional("feat(APP-1): support rating", "fix(APP-1): handle nil rating (#20)", "APP-2 direct push")
+	pr := github.PullRequest{
+		Number:    12,
+		Title:     "Support rating",
+		Author:    "dev",
+		Labels:    []string{"feature"},
+		MergedAt:  time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC),
+		Reviewers: []string{"reviewer"},
+		URL:       "https://github.com/Adarga-Ltd/some-service/pull/12",
+	}
+
+	note := notes.ReleaseNote{
+		RepoName: "some-service",
+		Issues: []notes.IssueCommits{
+			{Issue: issue("APP-1", "Story", "Done"), Commits: commits[:2]},
+			{Issue: issue("APP-2", "Story", "Done"), Commits: commits[2:]},
+		},
+		Commits: commits,
+		PullRequests: map[string][]github.PullRequest{
+			commits[0].Hash.String(): {pr},
+			commits[1].Hash.String(): {pr},
+		},
+	}
+
+	out, err := note.String(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out, "/pull/"), out)
+	assert.Contains(t, out, "    - https://github.com/Adarga-Ltd/some-service/pull/12")
+
+	doc := notes.NewDocument(cfg, notes.Release{Notes: []notes.ReleaseNote{note}})
+	require.Len(t, doc.Repos[0].Commits, 3)
+	assert.Equal(t, 12, doc.Repos[0].Commits[1].PR)
+	require.NotNil(t, doc.Repos[0].Commits[1].PullRequest)
+	assert.Equal(t, []string{"reviewer"}, doc.Repos[0].Commits[1].PullRequest.Reviewers)
+	assert.Nil(t, doc.Repos[0].Commits[2].PullRequest)
+	assert.Zero(t, doc.Repos[0].Commits[2].PR)
+}
+
+func TestSubjectPullRequests(t *testing.T) {
+	// without GitHub only the (#123) suffix is used, commits without one get no link
+	note := notes.ReleaseNote{
+		RepoName: "some-service",
+		Issues: []notes.IssueCommits{
+			{Issue: issue("APP-1", "Story", "Done"), Commits: conventional("feat(APP-1): support rating (#12)", "fix(APP-1): rebased")},
+		},
+	}
+
+	out, err := note.String(config.Default())
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out, "/pull/"), out)
+	assert.Contains(t, out, "    - https://github.com/Adarga-Ltd/some-service/pull/12")
+}
@@ -0,0 +1,44 @@
+package notes_test
+
+import (
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRollback(t *testing.T) {
+	cfg := config.Default()
+
+	rolledBack := conventional("feat(APP-2): risky change (#30)", "fix: untracked tweak (#31)")
+	release := notes.Release{
+		Notes: []notes.ReleaseNote{
+			{
+				RepoName:  "some-service",
+				Tag1:      "1.2.0",
+				Tag2:      "1.1.0",
+				Rollback:  true,
+				Issues:    []notes.IssueCommits{{Issue: issue("APP-2", "Story", "Done"), Commits: rolledBack[:1]}},
+				Commits:   rolledBack,
+				Untracked: rolledBack[1:],
+			},
+			{
+				RepoName: "other-service",
+				Tag1:     "1.0.0",
+				Tag2:     "1.1.0",
+				Issues:   []notes.IssueCommits{{Issue: issue("APP-1", "Story", "Done"), Commits: conventional("feat(APP-1): support rating (#12)")}},
+			},
+		},
+	}
+
+	out, err := release.Markdown(zap.NewNop(), cfg)
+	require.NoError(t, err)
+	assertGolden(t, "rollback", out)
+
+	doc := notes.NewDocument(cfg, release)
+	assert.True(t, doc.Repos[0].Rollback)
+	assert.False(t, doc.Repos[1].Rollback)
+}
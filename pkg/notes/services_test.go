@@ -0,0 +1,57 @@
+package notes_test
+
+import (
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/alex-emery/release-notes/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewAndRemovedServices(t *testing.T) {
+	cfg := config.Default()
+
+	release := notes.Release{
+		Images: []git.ImageDiff{
+			{Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/new-service", Kind: git.ImageAdded, Tag2: "0.1.0"},
+			{Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/other-service", Kind: git.ImageRetagged, Tag1: "1.0.0", Tag2: "1.1.0"},
+			{Environment: "prod", Namespace: "wb-lfqa", Name: "adarga/removed-service", Kind: git.ImageRemoved, Tag1: "2.0.0"},
+			{Environment: "prod", Namespace: "wb-other", Name: "adarga/other-service", Kind: git.ImageRetagged, Tag1: "1.0.0", Tag2: "1.1.0"},
+			{Environment: "prod", Namespace: "wb-other", Name: "adarga/pinned", Kind: git.ImageDigest, Tag1: "1.0.0", Tag2: "1.0.0"},
+		},
+		Notes: []notes.ReleaseNote{
+			{
+				RepoName: "removed-service",
+				Image:    "adarga/removed-service",
+				Tag1:     "2.0.0",
+				Kind:     git.ImageRemoved,
+			},
+			{
+				RepoName: "new-service",
+				Image:    "adarga/new-service",
+				Tag2:     "0.1.0",
+				Kind:     git.ImageAdded,
+				Issues:   []notes.IssueCommits{{Issue: issue("APP-3", "Story", "Done"), Commits: conventional("feat(APP-3): initial service (#1)")}},
+			},
+			{
+				RepoName: "other-service",
+				Tag1:     "1.0.0",
+				Tag2:     "1.1.0",
+				Kind:     git.ImageRetagged,
+				Issues:   []notes.IssueCommits{{Issue: issue("APP-1", "Story", "Done"), Commits: conventional("feat(APP-1): support rating (#12)")}},
+			},
+		},
+	}
+
+	out, err := release.Markdown(zap.NewNop(), cfg)
+	require.NoError(t, err)
+	assertGolden(t, "services", out)
+
+	doc := notes.NewDocument(cfg, release)
+	require.Len(t, doc.Repos, 3)
+	assert.Equal(t, string(git.ImageRemoved), doc.Repos[0].Kind)
+	assert.Equal(t, string(git.ImageAdded), doc.Repos[1].Kind)
+}
@@ -0,0 +1,106 @@
+package notes
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+)
+
+// Sort puts the notes in a stable order, so the same release always renders the same way.
+// Repos with a configured priority come first (lowest first), the rest are alphabetical,
+// notes for the same repo are ordered by image, tags and kind.
+// Issues are ordered by type, status and then key.
+func (r *Release) Sort(cfg *config.Config) {
+	sortNotes(cfg, r.Notes)
+}
+
+func sortNotes(cfg *config.Config, notes []ReleaseNote) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		pi, hasI := repoPriority(cfg, notes[i].RepoName)
+		pj, hasJ := repoPriority(cfg, notes[j].RepoName)
+		if hasI != hasJ {
+			return hasI
+		}
+
+		if pi != pj {
+			return pi < pj
+		}
+
+		a, b := notes[i], notes[j]
+		if a.RepoName != b.RepoName {
+			return a.RepoName < b.RepoName
+		}
+
+		// a repo deployed at different ranges has a note per range
+		if a.Image != b.Image {
+			return a.Image < b.Image
+		}
+
+		if a.Tag1 != b.Tag1 {
+			return a.Tag1 < b.Tag1
+		}
+
+		if a.Tag2 != b.Tag2 {
+			return a.Tag2 < b.Tag2
+		}
+
+		return a.Kind < b.Kind
+	})
+
+	for _, note := range notes {
+		sortIssues(note.Issues)
+	}
+}
+
+func repoPriority(cfg *config.Config, repo string) (int, bool) {
+	r, ok := cfg.Repos[repo]
+	if !ok || r.Priority == 0 {
+		return 0, false
+	}
+
+	return r.Priority, true
+}
+
+func sortIssues(issues []IssueCommits) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i].Issue, issues[j].Issue
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+
+		if a.Status != b.Status {
+			return a.Status < b.Status
+		}
+
+		return compareKeys(a.Key, b.Key) < 0
+	})
+}
+
+// compareKeys orders keys with the same prefix numerically, so APP-9 comes before APP-10.
+func compareKeys(a, b string) int {
+	prefixA, numA := splitKey(a)
+	prefixB, numB := splitKey(b)
+
+	if prefixA != prefixB || numA < 0 || numB < 0 {
+		return strings.Compare(a, b)
+	}
+
+	return numA - numB
+}
+
+// splits APP-123 into APP- and 123, the number is -1 if the key doesn't end in one.
+func splitKey(key string) (string, int) {
+	i := len(key)
+	for i > 0 && key[i-1] >= '0' && key[i-1] <= '9' {
+		i--
+	}
+
+	num, err := strconv.Atoi(key[i:])
+	if err != nil {
+		return key, -1
+	}
+
+	return key[:i], num
+}
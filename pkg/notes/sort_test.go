@@ -0,0 +1,128 @@
+package notes_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/git"
+	"github.com/alex-emery/release-notes/pkg/notes"
+	"github.com/alex-emery/release-notes/pkg/tracker"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func issue(key, issueType, status string) *tracker.Issue {
+	return &tracker.Issue{
+		Key:     key,
+		Summary: "Summary of " + key,
+		Status:  status,
+		Type:    issueType,
+		URL:     "https://adarga.atlassian.net/browse/" + key,
+	}
+}
+
+func commits(messages ...string) []object.Commit {
+	result := make([]object.Commit, 0, len(messages))
+	for _, message := range messages {
+		result = append(result, object.Commit{Message: message})
+	}
+
+	return result
+}
+
+// a release with everything out of order, shuffled differently on every call.
+func unorderedRelease(r *rand.Rand) notes.Release {
+	release := notes.Release{
+		Notes: []notes.ReleaseNote{
+			{
+				RepoName: "zebra-service",
+				Issues: []notes.IssueCommits{
+					{Issue: issue("APP-10", "Story", "Done"), Commits: commits("feat: APP-10 (#100)", "fix: APP-10 (#9)", "fix: APP-10 again (#10)")},
+					{Issue: issue("APP-9", "Story", "Done"), Commits: commits("feat: APP-9 (#8)")},
+					{Issue: issue("APP-2", "Bug", "In Progress"), Commits: commits("fix: APP-2 (#7)")},
+					{Issue: issue("APP-1", "Story", "Backlog"), Commits: commits("feat: APP-1 (#6)")},
+				},
+			},
+			{
+				RepoName: "alpha-service",
+				Issues: []notes.IssueCommits{
+					{Issue: issue("PLAT-3", "Task", "Done"), Commits: commits("chore: PLAT-3 (#3)")},
+					{Issue: issue("APP-3", "Task", "Done"), Commits: commits("chore: APP-3 (#2)")},
+				},
+			},
+			{
+				RepoName: "some-service",
+				Image:    "adarga/some-service",
+				Tag1:     "1.3.0",
+				Tag2:     "1.4.0",
+				Kind:     git.ImageRetagged,
+				Issues: []notes.IssueCommits{
+					{Issue: issue("APP-7", "Story", "Done"), Commits: commits("feat: APP-7 (#12)")},
+				},
+			},
+			{
+				RepoName: "some-service",
+				Image:    "adarga/some-service",
+				Tag1:     "1.2.3",
+				Tag2:     "1.3.0",
+				Kind:     git.ImageRetagged,
+				Issues: []notes.IssueCommits{
+					{Issue: issue("APP-6", "Story", "Done"), Commits: commits("feat: APP-6 (#11)")},
+				},
+			},
+			{
+				RepoName: "gateway",
+				Issues: []notes.IssueCommits{
+					{Issue: issue("APP-5", "Bug", "Done"), Commits: commits("fix: APP-5 (#1)")},
+				},
+			},
+		},
+	}
+
+	r.Shuffle(len(release.Notes), func(i, j int) {
+		release.Notes[i], release.Notes[j] = release.Notes[j], release.Notes[i]
+	})
+
+	for _, note := range release.Notes {
+		r.Shuffle(len(note.Issues), func(i, j int) {
+			note.Issues[i], note.Issues[j] = note.Issues[j], note.Issues[i]
+		})
+
+		for _, ic := range note.Issues {
+			r.Shuffle(len(ic.Commits), func(i, j int) {
+				ic.Commits[i], ic.Commits[j] = ic.Commits[j], ic.Commits[i]
+			})
+		}
+	}
+
+	return release
+}
+
+func TestDeterministicOrder(t *testing.T) {
+	cfg := config.Default()
+	cfg.Repos = map[string]config.Repo{
+		"gateway": {Priority: 1},
+	}
+
+	first := ""
+	for seed := int64(0); seed < 20; seed++ {
+		release := unorderedRelease(rand.New(rand.NewSource(seed)))
+		release.Sort(cfg)
+
+		out := notes.ReleaseNoteToString(zap.NewNop(), cfg, release.Notes...)
+
+		doc, err := notes.NewDocument(cfg, release).Encode(notes.FormatJSON)
+		require.NoError(t, err)
+		out += doc
+
+		if seed == 0 {
+			first = out
+			assertGolden(t, "ordering", out)
+			continue
+		}
+
+		require.Equal(t, first, out, "seed %d rendered differently", seed)
+	}
+}
@@ -0,0 +1,92 @@
+package notes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+)
+
+const (
+	notesTemplate       = "notes.template"
+	envTemplate         = "env.template"
+	groupedTemplate     = "grouped.template"
+	breakingTemplate    = "breaking.template"
+	rollbackTemplate    = "rollback.template"
+	deploymentsTemplate = "deployments.template"
+)
+
+// templateFuncs are available to every template, embedded or user supplied.
+var templateFuncs = template.FuncMap{
+	// md escapes text so it renders literally in Markdown.
+	// {{ .Summary | md }}
+	"md": EscapeMarkdown,
+	// truncate cuts s down to n characters, adding an ellipsis when it was cut.
+	// {{ .Summary | truncate 50 }}
+	"truncate": func(n int, s string) string {
+		runes := []rune(s)
+		if len(runes) <= n {
+			return s
+		}
+
+		if n <= 1 {
+			return string(runes[:n])
+		}
+
+		return string(runes[:n-1]) + "…"
+	},
+	// {{ .Labels | join ", " }}
+	"join": func(sep string, values []string) string {
+		return strings.Join(values, sep)
+	},
+	// date formats a time with a Go layout.
+	// {{ .Date | date "2006-01-02" }}
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	// ticketLink renders an issue as a Markdown link.
+	// {{ ticketLink . }}
+	"ticketLink": func(issue IssueTemplate) string {
+		if issue.URL == "" {
+			return EscapeMarkdown(issue.ID)
+		}
+
+		return fmt.Sprintf("[%s](%s)", EscapeMarkdown(issue.ID), issue.URL)
+	},
+}
+
+// loadTemplate reads name from, in order, the file configured for it,
+// the configured templates dir, and finally the embedded defaults.
+func loadTemplate(cfg *config.Config, name string) (*template.Template, error) {
+	path := ""
+	switch name {
+	case notesTemplate:
+		path = cfg.Templates.Notes
+	case envTemplate:
+		path = cfg.Templates.Env
+	}
+
+	if path == "" && cfg.Templates.Dir != "" {
+		candidate := filepath.Join(cfg.Templates.Dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			path = candidate
+		}
+	}
+
+	tmpl := template.New(name).Funcs(templateFuncs)
+
+	if path == "" {
+		return tmpl.ParseFS(templateFS, name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+
+	return tmpl.Parse(string(data))
+}
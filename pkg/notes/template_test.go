@@ -0,0 +1,47 @@
+package notes_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestUserTemplates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.template"), []byte(
+		`{{.Name}} {{.Tag1}}..{{.Tag2}}{{range .Issues}} {{ticketLink .}} {{.Summary | truncate 10}} [{{.Labels | join ","}}]{{end}}{{range .Commits}} {{.ShortSHA}} {{.Date | date "2006-01-02"}}{{end}} by {{.Authors | join ", "}}`,
+	), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "env.template"), []byte(
+		`{{.Totals.Repos}} repos, {{.Totals.Issues}} issues, {{.Totals.Commits}} commits, {{.Totals.Authors}} authors
+{{.Notes}}`,
+	), 0644))
+
+	cfg := config.Default()
+	cfg.Templates.Dir = dir
+
+	out, err := testRelease().Markdown(zap.NewNop(), cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, `1 repos, 1 issues, 1 commits, 1 authors
+## Release Notes
+
+### Deployments
+
+- prod / wb-lfqa: some-service 1.0.0 → 1.1.0
+
+some-service 1.0.0..1.1.0 [APP-1](https://adarga.atlassian.net/browse/APP-1) Support a… [backend] 3eb443b 2023-10-01 by Some Dev
+`, out)
+}
+
+func TestEmbeddedTemplates(t *testing.T) {
+	out, err := testRelease().Markdown(zap.NewNop(), config.Default())
+	require.NoError(t, err)
+
+	assert.Contains(t, out, "### Some Service")
+	assert.Contains(t, out, "### Checklist")
+}
@@ -0,0 +1,83 @@
+package notes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// untrackedFilter drops noise, i.e dependency bumps or bot commits, from the untracked commits.
+type untrackedFilter struct {
+	patterns []*regexp.Regexp
+	authors  map[string]bool
+}
+
+func newUntrackedFilter(cfg *config.Config) (*untrackedFilter, error) {
+	filter := &untrackedFilter{
+		authors: map[string]bool{},
+	}
+
+	for _, pattern := range cfg.Untracked.IgnorePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile ignore pattern %s: %w", pattern, err)
+		}
+		filter.patterns = append(filter.patterns, re)
+	}
+
+	for _, author := range cfg.Untracked.IgnoreAuthors {
+		filter.authors[strings.ToLower(author)] = true
+	}
+
+	return filter, nil
+}
+
+func (f *untrackedFilter) ignore(commit object.Commit) bool {
+	if f.authors[strings.ToLower(commit.Author.Name)] || f.authors[strings.ToLower(commit.Author.Email)] {
+		return true
+	}
+
+	subject := strings.Split(commit.Message, "\n")[0]
+	for _, re := range f.patterns {
+		if re.MatchString(subject) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// untrackedCommits returns the commits that aren't attached to a tracked ticket, tracked are the commits of each one.
+// Merge commits are left out, the commits they merge are listed instead.
+func untrackedCommits(filter *untrackedFilter, commits []object.Commit, trackedKeys map[string][]object.Commit) []object.Commit {
+	tracked := map[string]bool{}
+	for _, keyCommits := range trackedKeys {
+		for _, commit := range keyCommits {
+			tracked[commit.Hash.String()] = true
+		}
+	}
+
+	untracked := []object.Commit{}
+	for _, commit := range commits {
+		if tracked[commit.Hash.String()] || len(commit.ParentHashes) > 1 || filter.ignore(commit) {
+			continue
+		}
+
+		untracked = append(untracked, commit)
+	}
+
+	return untracked
+}
+
+// UntrackedCount is the number of untracked commits across every repo in the release.
+func (r Release) UntrackedCount() int {
+	count := 0
+	for _, note := range r.Notes {
+		count += len(note.Untracked)
+	}
+
+	return count
+}
@@ -0,0 +1,117 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alex-emery/release-notes/pkg/config"
+	"github.com/alex-emery/release-notes/pkg/tracker"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func commit(sha, message, author string, parents int) object.Commit {
+	c := object.Commit{
+		Hash:    plumbing.NewHash(sha),
+		Message: message,
+		Author:  object.Signature{Name: author, Email: author + "@example.com"},
+	}
+	for i := 0; i < parents; i++ {
+		c.ParentHashes = append(c.ParentHashes, plumbing.ZeroHash)
+	}
+
+	return c
+}
+
+func TestUntrackedCommits(t *testing.T) {
+	tracked := commit("1111111111111111111111111111111111111111", "feat: APP-1 rating (#1)", "dev", 1)
+	untracked := commit("2222222222222222222222222222222222222222", "fix: typo in readme (#2)", "dev", 1)
+	merge := commit("3333333333333333333333333333333333333333", "Merge branch 'main'", "dev", 2)
+	bump := commit("4444444444444444444444444444444444444444", "chore(deps): bump zap (#3)", "dev", 1)
+	bot := commit("5555555555555555555555555555555555555555", "update lockfile (#4)", "dependabot[bot]", 1)
+
+	cfg := config.Default()
+	cfg.Untracked.IgnorePatterns = []string{`^chore\(deps\)`}
+	cfg.Untracked.IgnoreAuthors = []string{"Dependabot[bot]"}
+
+	filter, err := newUntrackedFilter(cfg)
+	require.NoError(t, err)
+
+	keys := map[string][]object.Commit{"APP-1": {tracked}}
+	commits := []object.Commit{tracked, untracked, merge, bump, bot}
+
+	assert.Equal(t, []object.Commit{untracked}, untrackedCommits(filter, commits, keys))
+	assert.Equal(t, []object.Commit{untracked, bump, bot}, untrackedCommits(&untrackedFilter{}, commits, keys))
+}
+
+// downTracker fails every batch fetch, like Jira during an outage.
+type downTracker struct {
+	tracker.IssueTracker
+}
+
+func (downTracker) Issues(ctx context.Context, keys []string) (map[string]*tracker.Issue, error) {
+	return nil, errors.New("service unavailable")
+}
+
+func TestUntrackedWhenTrackerFails(t *testing.T) {
+	tracked := commit("1111111111111111111111111111111111111111", "feat: APP-1 rating (#1)", "dev", 1)
+	untracked := commit("2222222222222222222222222222222222222222", "fix: typo in readme (#2)", "dev", 1)
+
+	repo := repoCommits{
+		repoName: "some-service",
+		tracker:  config.TrackerJira,
+		commits:  []object.Commit{tracked, untracked},
+		keys:     map[string][]object.Commit{"APP-1": {tracked}},
+	}
+
+	notes := resolveIssues(context.Background(), zap.NewNop(), config.Default(), tracker.Trackers{config.TrackerJira: downTracker{}}, repo)
+	require.Len(t, notes, 1)
+	assert.Empty(t, notes[0].Issues)
+	// the commit references a ticket, it's tracked even though the ticket couldn't be fetched
+	assert.Equal(t, []object.Commit{untracked}, notes[0].Untracked)
+}
+
+// partialTracker only knows the issues it's given, like a tracker asked for a typo'd key.
+type partialTracker struct {
+	tracker.IssueTracker
+	issues map[string]*tracker.Issue
+}
+
+func (p partialTracker) Issues(ctx context.Context, keys []string) (map[string]*tracker.Issue, error) {
+	found := map[string]*tracker.Issue{}
+	for _, key := range keys {
+		if issue, ok := p.issues[key]; ok {
+			found[key] = issue
+		}
+	}
+
+	return found, nil
+}
+
+func TestUntrackedWhenTicketNotFound(t *testing.T) {
+	tracked := commit("1111111111111111111111111111111111111111", "feat: APP-1 rating (#1)", "dev", 1)
+	typo := commit("2222222222222222222222222222222222222222", "fix: APP-99999 the typo'd ticket (#2)", "dev", 1)
+
+	repo := repoCommits{
+		repoName: "some-service",
+		tracker:  config.TrackerJira,
+		commits:  []object.Commit{tracked, typo},
+		keys: map[string][]object.Commit{
+			"APP-1":     {tracked},
+			"APP-99999": {typo},
+		},
+	}
+
+	issue := &tracker.Issue{Key: "APP-1", Summary: "rating"}
+	trackers := tracker.Trackers{config.TrackerJira: partialTracker{issues: map[string]*tracker.Issue{"APP-1": issue}}}
+
+	notes := resolveIssues(context.Background(), zap.NewNop(), config.Default(), trackers, repo)
+	require.Len(t, notes, 1)
+	assert.Equal(t, []IssueCommits{{Issue: issue, Commits: []object.Commit{tracked}}}, notes[0].Issues)
+	// the tracker doesn't know the ticket, so the commit would otherwise be in neither list
+	assert.Equal(t, []object.Commit{typo}, notes[0].Untracked)
+}
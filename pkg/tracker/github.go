@@ -0,0 +1,104 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alex-emery/release-notes/pkg/httpretry"
+	"github.com/google/go-github/v56/github"
+	"go.uber.org/zap"
+)
+
+// GitHub resolves #123 references against GitHub Issues.
+// Keys are fully qualified, i.e Adarga-Ltd/some-service#123
+type GitHub struct {
+	client *github.Client
+	org    string
+	logger *zap.Logger
+}
+
+func NewGitHub(logger *zap.Logger, org, token string) *GitHub {
+	return &GitHub{
+		client: github.NewClient(&http.Client{Transport: httpretry.NewTransport(logger, nil)}).WithAuthToken(token),
+		org:    org,
+		logger: logger,
+	}
+}
+
+// Key qualifies #123 with the org and repo it was found in,
+// references that already name a repo (some-org/other-repo#123) are left alone.
+func (g *GitHub) Key(repo, ref string) string {
+	if strings.HasPrefix(ref, "#") {
+		return fmt.Sprintf("%s/%s%s", g.org, repo, ref)
+	}
+
+	return ref
+}
+
+func (g *GitHub) Issue(ctx context.Context, key string) (*Issue, error) {
+	owner, repo, number, err := parseGitHubKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	g.logger.Debug("searching for issue", zap.String("issueID", key))
+	found, _, err := g.client.Issues.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issue %s: %w", key, err)
+	}
+
+	// PRs are issues as far as the API is concerned, (#123) in a squash merge is the PR not an issue.
+	if found.IsPullRequest() {
+		return nil, fmt.Errorf("%s is a pull request: %w", key, ErrNotFound)
+	}
+
+	labels := make([]string, 0, len(found.Labels))
+	for _, label := range found.Labels {
+		labels = append(labels, label.GetName())
+	}
+
+	return &Issue{
+		Key:     key,
+		Summary: found.GetTitle(),
+		Status:  found.GetState(),
+		Type:    "Issue",
+		Labels:  labels,
+		URL:     found.GetHTMLURL(),
+	}, nil
+}
+
+func (g *GitHub) Issues(ctx context.Context, keys []string) (map[string]*Issue, error) {
+	return issuesOneByOne(ctx, g.logger, g, keys), nil
+}
+
+func (g *GitHub) BrowseURL(key string) string {
+	owner, repo, number, err := parseGitHubKey(key)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("https://github.com/%s/%s/issues/%d", owner, repo, number)
+}
+
+// splits owner/repo#123
+func parseGitHubKey(key string) (string, string, int, error) {
+	name, num, ok := strings.Cut(key, "#")
+	if !ok {
+		return "", "", 0, fmt.Errorf("invalid GitHub issue key %s", key)
+	}
+
+	owner, repo, ok := strings.Cut(name, "/")
+	if !ok {
+		return "", "", 0, fmt.Errorf("invalid GitHub issue key %s: missing owner/repo", key)
+	}
+
+	number, err := strconv.Atoi(num)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid GitHub issue key %s: %w", key, err)
+	}
+
+	return owner, repo, number, nil
+}
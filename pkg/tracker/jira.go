@@ -0,0 +1,123 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/alex-emery/release-notes/pkg/httpretry"
+	jira "github.com/andygrunwald/go-jira/v2/cloud"
+	"go.uber.org/zap"
+)
+
+// the only fields used when rendering notes, everything else is left out of responses.
+var jiraFields = []string{"summary", "status", "labels", "issuetype"}
+
+type Jira struct {
+	client    *jira.Client
+	host      string
+	batchSize int
+	logger    *zap.Logger
+}
+
+// NewJira creates a Jira tracker that fetches issues batchSize keys at a time.
+func NewJira(logger *zap.Logger, host, email, token string, batchSize int) (*Jira, error) {
+	if batchSize < 1 {
+		return nil, fmt.Errorf("jira batch size must be at least 1, got %d", batchSize)
+	}
+
+	tp := jira.BasicAuthTransport{
+		Username:  email,
+		APIToken:  token,
+		Transport: httpretry.NewTransport(logger, nil),
+	}
+
+	client, err := jira.NewClient(host, tp.Client())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jira client: %w", err)
+	}
+
+	return &Jira{
+		client:    client,
+		host:      strings.TrimSuffix(host, "/"),
+		batchSize: batchSize,
+		logger:    logger,
+	}, nil
+}
+
+func (j *Jira) Key(_, ref string) string {
+	return ref
+}
+
+func (j *Jira) Issue(ctx context.Context, key string) (*Issue, error) {
+	j.logger.Debug("searching for issue", zap.String("issueID", key))
+	found, _, err := j.client.Issue.Get(ctx, key, &jira.GetQueryOptions{
+		Fields: strings.Join(jiraFields, ","),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issue %s: %w", key, err)
+	}
+
+	return j.toIssue(found), nil
+}
+
+// Issues fetches the keys with JQL searches, i.e key in (APP-1, APP-2), batchSize keys per request.
+func (j *Jira) Issues(ctx context.Context, keys []string) (map[string]*Issue, error) {
+	issues := make(map[string]*Issue, len(keys))
+	for start := 0; start < len(keys); start += j.batchSize {
+		end := start + j.batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+
+		jql := fmt.Sprintf("key in (%s)", strings.Join(batch, ","))
+		j.logger.Debug("searching for issues", zap.String("jql", jql))
+
+		found, _, err := j.client.Issue.Search(ctx, jql, &jira.SearchOptions{
+			MaxResults: len(batch),
+			Fields:     jiraFields,
+			// unknown keys are reported as warnings rather than failing the whole batch.
+			ValidateQuery: "warn",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for issues %s: %w", jql, err)
+		}
+
+		for i := range found {
+			issues[found[i].Key] = j.toIssue(&found[i])
+		}
+	}
+
+	for _, key := range keys {
+		if _, ok := issues[key]; !ok {
+			j.logger.Debug("issue not found", zap.String("issueID", key))
+		}
+	}
+
+	return issues, nil
+}
+
+func (j *Jira) BrowseURL(key string) string {
+	return j.host + "/browse/" + key
+}
+
+func (j *Jira) toIssue(found *jira.Issue) *Issue {
+	issue := &Issue{
+		Key: found.Key,
+		URL: j.BrowseURL(found.Key),
+	}
+
+	if found.Fields == nil {
+		return issue
+	}
+
+	issue.Summary = found.Fields.Summary
+	issue.Labels = found.Fields.Labels
+	issue.Type = found.Fields.Type.Name
+	if found.Fields.Status != nil {
+		issue.Status = found.Fields.Status.Name
+	}
+
+	return issue
+}
@@ -0,0 +1,155 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alex-emery/release-notes/pkg/httpretry"
+	"go.uber.org/zap"
+)
+
+const linearAPI = "https://api.linear.app/graphql"
+
+// the fields requested for every issue
+const linearIssueFields = `identifier title url state { name } labels { nodes { name } }`
+
+// Linear resolves issue identifiers (i.e ENG-123) through the Linear GraphQL API.
+type Linear struct {
+	client    *http.Client
+	endpoint  string
+	workspace string
+	apiKey    string
+	logger    *zap.Logger
+}
+
+func NewLinear(logger *zap.Logger, workspace, apiKey string) *Linear {
+	return &Linear{
+		client:    &http.Client{Transport: httpretry.NewTransport(logger, nil)},
+		endpoint:  linearAPI,
+		workspace: workspace,
+		apiKey:    apiKey,
+		logger:    logger,
+	}
+}
+
+type linearIssue struct {
+	Identifier string `json:"identifier"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	State      struct {
+		Name string `json:"name"`
+	} `json:"state"`
+	Labels struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+}
+
+type linearResponse struct {
+	Data   map[string]*linearIssue `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (l *Linear) Key(_, ref string) string {
+	return ref
+}
+
+func (l *Linear) Issue(ctx context.Context, key string) (*Issue, error) {
+	issues, err := l.Issues(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+
+	issue, ok := issues[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+
+	return issue, nil
+}
+
+// Issues fetches every key in a single request by aliasing an issue query per key.
+func (l *Linear) Issues(ctx context.Context, keys []string) (map[string]*Issue, error) {
+	if len(keys) == 0 {
+		return map[string]*Issue{}, nil
+	}
+
+	query := strings.Builder{}
+	query.WriteString("query {")
+	for i, key := range keys {
+		fmt.Fprintf(&query, " i%d: issue(id: %q) { %s }", i, key, linearIssueFields)
+	}
+	query.WriteString(" }")
+
+	body, err := json.Marshal(map[string]string{"query": query.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", l.apiKey)
+
+	l.logger.Debug("searching for issues", zap.Strings("issueIDs", keys))
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query linear: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query linear: %s", resp.Status)
+	}
+
+	result := linearResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode linear response: %w", err)
+	}
+
+	// unknown identifiers come back as null with an error, the rest of the data is still valid.
+	for _, e := range result.Errors {
+		l.logger.Debug("linear returned an error", zap.String("message", e.Message))
+	}
+
+	issues := make(map[string]*Issue, len(keys))
+	for i, key := range keys {
+		found := result.Data[fmt.Sprintf("i%d", i)]
+		if found == nil {
+			continue
+		}
+
+		labels := make([]string, 0, len(found.Labels.Nodes))
+		for _, label := range found.Labels.Nodes {
+			labels = append(labels, label.Name)
+		}
+
+		issues[key] = &Issue{
+			Key:     found.Identifier,
+			Summary: found.Title,
+			Status:  found.State.Name,
+			Type:    "Issue",
+			Labels:  labels,
+			URL:     found.URL,
+		}
+	}
+
+	if len(issues) == 0 && len(result.Errors) > 0 {
+		return nil, fmt.Errorf("failed to query linear: %s", result.Errors[0].Message)
+	}
+
+	return issues, nil
+}
+
+func (l *Linear) BrowseURL(key string) string {
+	return fmt.Sprintf("https://linear.app/%s/issue/%s", l.workspace, key)
+}
@@ -0,0 +1,69 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotFound is returned when a reference doesn't point at an issue,
+// i.e a GitHub PR number or a Linear identifier that doesn't exist.
+var ErrNotFound = errors.New("issue not found")
+
+// Issue is the tracker agnostic view of a ticket used when rendering notes.
+type Issue struct {
+	Key     string
+	Summary string
+	Status  string
+	Type    string
+	Labels  []string
+	URL     string
+}
+
+// IssueTracker fetches the issues referenced by commits.
+type IssueTracker interface {
+	// Key turns a reference found in a commit of repo into a key the tracker can fetch,
+	// i.e #123 in some-service becomes Adarga-Ltd/some-service#123 for GitHub Issues.
+	Key(repo, ref string) string
+	// Issue fetches a single issue.
+	Issue(ctx context.Context, key string) (*Issue, error)
+	// Issues fetches several issues at once, keys that can't be found are left out of the result.
+	Issues(ctx context.Context, keys []string) (map[string]*Issue, error)
+	// BrowseURL is the web URL for the issue.
+	BrowseURL(key string) string
+}
+
+// Trackers holds the configured trackers by name, i.e jira, github or linear.
+type Trackers map[string]IssueTracker
+
+func (t Trackers) Get(name string) (IssueTracker, error) {
+	tracker, ok := t[name]
+	if !ok {
+		return nil, fmt.Errorf("issue tracker %s is not configured", name)
+	}
+
+	return tracker, nil
+}
+
+// fetches each key one at a time, for trackers without a batch API.
+// Issues that fail to be fetched are logged and skipped.
+func issuesOneByOne(ctx context.Context, logger *zap.Logger, tracker IssueTracker, keys []string) map[string]*Issue {
+	issues := make(map[string]*Issue, len(keys))
+	for _, key := range keys {
+		issue, err := tracker.Issue(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			logger.Debug("skipping reference", zap.String("issueID", key), zap.Error(err))
+			continue
+		}
+
+		if err != nil {
+			logger.Error("failed to find issue", zap.String("issueID", key), zap.Error(err))
+			continue
+		}
+		issues[key] = issue
+	}
+
+	return issues
+}
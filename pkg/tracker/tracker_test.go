@@ -0,0 +1,136 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGitHubKey(t *testing.T) {
+	gh := NewGitHub(zap.NewNop(), "Adarga-Ltd", "")
+
+	testCases := []struct {
+		ref      string
+		expected string
+		url      string
+	}{
+		{
+			ref:      "#123",
+			expected: "Adarga-Ltd/some-service#123",
+			url:      "https://github.com/Adarga-Ltd/some-service/issues/123",
+		},
+		{
+			ref:      "other-org/other-repo#4",
+			expected: "other-org/other-repo#4",
+			url:      "https://github.com/other-org/other-repo/issues/4",
+		},
+	}
+
+	for _, tc := range testCases {
+		key := gh.Key("some-service", tc.ref)
+		assert.Equal(t, tc.expected, key)
+		assert.Equal(t, tc.url, gh.BrowseURL(key))
+	}
+}
+
+func TestLinearIssues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "api-key", r.Header.Get("Authorization"))
+
+		body := map[string]string{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.True(t, strings.Contains(body["query"], `i0: issue(id: "ENG-1")`))
+		assert.True(t, strings.Contains(body["query"], `i1: issue(id: "ENG-404")`))
+
+		_, _ = w.Write([]byte(`{
+			"data": {
+				"i0": {"identifier": "ENG-1", "title": "Add thing", "url": "https://linear.app/acme/issue/ENG-1", "state": {"name": "Done"}, "labels": {"nodes": [{"name": "backend"}]}},
+				"i1": null
+			},
+			"errors": [{"message": "Entity not found"}]
+		}`))
+	}))
+	defer server.Close()
+
+	linear := NewLinear(zap.NewNop(), "acme", "api-key")
+	linear.endpoint = server.URL
+
+	issues, err := linear.Issues(context.Background(), []string{"ENG-1", "ENG-404"})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+
+	assert.Equal(t, &Issue{
+		Key:     "ENG-1",
+		Summary: "Add thing",
+		Status:  "Done",
+		Type:    "Issue",
+		Labels:  []string{"backend"},
+		URL:     "https://linear.app/acme/issue/ENG-1",
+	}, issues["ENG-1"])
+}
+
+func TestJiraIssues(t *testing.T) {
+	batches := [][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/2/search", r.URL.Path)
+		assert.Equal(t, "summary,status,labels,issuetype", r.URL.Query().Get("fields"))
+		assert.Equal(t, "warn", r.URL.Query().Get("validateQuery"))
+
+		jql := r.URL.Query().Get("jql")
+		require.True(t, strings.HasPrefix(jql, "key in (") && strings.HasSuffix(jql, ")"), jql)
+		keys := strings.Split(strings.TrimSuffix(strings.TrimPrefix(jql, "key in ("), ")"), ",")
+		batches = append(batches, keys)
+
+		issues := []map[string]any{}
+		for _, key := range keys {
+			if key == "APP-404" {
+				continue
+			}
+
+			issues = append(issues, map[string]any{
+				"key": key,
+				"fields": map[string]any{
+					"summary":   "fix " + key,
+					"status":    map[string]string{"name": "Done"},
+					"labels":    []string{"backend"},
+					"issuetype": map[string]string{"name": "Bug"},
+				},
+			})
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]any{"issues": issues}))
+	}))
+	defer server.Close()
+
+	jira, err := NewJira(zap.NewNop(), server.URL, "me@example.com", "token", 2)
+	require.NoError(t, err)
+
+	issues, err := jira.Issues(context.Background(), []string{"APP-1", "APP-2", "APP-404", "APP-3", "APP-4"})
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{{"APP-1", "APP-2"}, {"APP-404", "APP-3"}, {"APP-4"}}, batches)
+	assert.Len(t, issues, 4)
+	assert.NotContains(t, issues, "APP-404")
+	assert.Equal(t, &Issue{
+		Key:     "APP-3",
+		Summary: "fix APP-3",
+		Status:  "Done",
+		Type:    "Bug",
+		Labels:  []string{"backend"},
+		URL:     server.URL + "/browse/APP-3",
+	}, issues["APP-3"])
+}
+
+func TestNewJiraBatchSize(t *testing.T) {
+	for _, batchSize := range []int{0, -1} {
+		_, err := NewJira(zap.NewNop(), "https://example.atlassian.net", "me@example.com", "token", batchSize)
+		assert.Error(t, err, batchSize)
+	}
+}